@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/audit"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"net/url"
+	"os"
+	"os/user"
+	"time"
+)
+
+// auditLog records every deletion when --audit-log is set.
+var auditLog *audit.Log
+
+// openAuditLog opens the log of --audit-log, close it once the command is
+// done.
+func openAuditLog(c *cli.Context) error {
+	path := c.String("audit-log")
+	if path == "" {
+		return nil
+	}
+	if path == "-" && c.Bool("json") {
+		return errors.New("--audit-log - writes to stdout, like --json, give it a file")
+	}
+	log, err := audit.Open(path, int64(c.Int("audit-max-size"))<<20, c.Int("audit-backups"))
+	if err != nil {
+		return err
+	}
+	log.Operator = c.String("audit-operator")
+	auditLog = log
+	return nil
+}
+
+// defaultOperator is the user running the command.
+func defaultOperator() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// auditedDelete deletes the manifest of image:tag, recorded pending before
+// the request and with its result after it. Nothing is deleted when the
+// audit log can't be written.
+func auditedDelete(r registry.Registry, plan imagePlan, tag, digest string) error {
+	record := audit.Record{
+		ID:       audit.NewID(),
+		Time:     time.Now(),
+		Registry: registryName(r),
+		Image:    plan.Image,
+		Tag:      tag,
+		Digest:   digest,
+		Rule:     plan.Rule,
+		Operator: plan.Operator,
+		Result:   audit.ResultPending,
+	}
+	if err := auditLog.Write(record); err != nil {
+		return fmt.Errorf("audit log of %s:%s, %v", plan.Image, tag, err)
+	}
+
+	errDel := r.DeleteImage(plan.Image, tag, digest)
+	record.Time, record.Result = time.Now(), audit.ResultDeleted
+	if errDel != nil {
+		record.Result, record.Error = audit.ResultFailed, errDel.Error()
+	}
+	if err := auditLog.Write(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't write the audit log of %s:%s, %s\n", plan.Image, tag, err.Error())
+	}
+	return errDel
+}
+
+// registryName is the registry url without credentials.
+func registryName(r registry.Registry) string {
+	parsed, err := url.Parse(r.BaseUrl)
+	if err != nil {
+		return r.BaseUrl
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// flagLabel names the rule of the delete flags in audit records and plans,
+// with the flags selecting tags.
+func flagLabel(rule config.Rule) string {
+	label := "delete"
+	for _, tag := range rule.Tags {
+		label += fmt.Sprintf(" --tag %q", tag.Pattern)
+		if tag.Keep != nil {
+			label += fmt.Sprintf(" --keep %d", *tag.Keep)
+		}
+	}
+	if rule.Keep != nil {
+		label += fmt.Sprintf(" --keep %d", *rule.Keep)
+	}
+	for _, flag := range []struct{ name, value string }{
+		{"constraint", rule.Constraint},
+		{"where", rule.Where},
+		{"older-than", rule.OlderThan},
+		{"delete-stale-groups", rule.DeleteStaleGroups},
+	} {
+		if flag.value != "" {
+			label += fmt.Sprintf(" --%s %q", flag.name, flag.value)
+		}
+	}
+	if rule.DeleteGoneBranches {
+		label += " --delete-gone-branches"
+	}
+	return label
+}
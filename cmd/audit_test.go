@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/r0mdau/go-clean-docker-registry/internal/audit"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditedDelete(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["master-1.0.0","master-1.1.0"]`,
+	})
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(path, 0, 0)
+	require.NoError(t, err)
+	log.Operator = "ops"
+	auditLog = log
+	defer func() { auditLog = nil }()
+
+	r := registry.NewRegistry(server.URL, false)
+	plan := imagePlan{Rule: "branches", Image: "r0mdau/nodejs", Tags: []string{"master-1.0.0"}}
+	require.Equal(t, 1, deleteTags(r, plan).Deleted)
+	require.Error(t, auditedDelete(r, imagePlan{Image: "r0mdau/missing", Operator: "alice"}, "v1", "sha256:v1"))
+	require.NoError(t, log.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []audit.Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record audit.Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+
+	require.Equal(t, records[0].ID, records[1].ID)
+	require.Equal(t, audit.ResultPending, records[0].Result)
+	require.Equal(t, audit.ResultDeleted, records[1].Result)
+	require.Equal(t, "branches", records[1].Rule)
+	require.Equal(t, "ops", records[1].Operator)
+	require.Equal(t, "master-1.0.0", records[1].Tag)
+	require.Equal(t, "sha256:master-1.0.0", records[1].Digest)
+	require.Equal(t, server.URL, records[1].Registry)
+
+	require.Equal(t, records[2].ID, records[3].ID)
+	require.NotEqual(t, records[0].ID, records[2].ID)
+	require.Equal(t, audit.ResultFailed, records[3].Result)
+	require.Equal(t, "alice", records[3].Operator)
+	require.NotEmpty(t, records[3].Error)
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	app := CreateApp()
+	for _, command := range app.Commands {
+		command.Action = openAuditLog
+	}
+	require.EqualError(t, app.Run([]string{"", "apply", "--audit-log", "-", "--json", "plan.json"}), "--audit-log - writes to stdout, like --json, give it a file")
+	require.NoError(t, app.Run([]string{"", "apply", "--audit-log", "-", "plan.json"}))
+	auditLog = nil
+}
+
+func TestFlagLabel(t *testing.T) {
+	keep := 10
+	require.Equal(t, `delete --tag "master-*" --keep 10 --tag "pr-*" --older-than "30d"`, flagLabel(config.Rule{
+		Tags:      []config.Tag{{Pattern: "master-*", Keep: &keep}, {Pattern: "pr-*"}},
+		OlderThan: "30d",
+	}))
+}
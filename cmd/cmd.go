@@ -3,8 +3,11 @@ package cmd
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
 	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/internal/notify"
 	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
 	"github.com/urfave/cli/v2"
 	"log"
@@ -35,16 +38,89 @@ func CreateApp() *cli.App {
 		Usage:    "Image name to delete ie r0mdau/nodejs",
 		Required: true,
 	}
-	tagFlag := &cli.StringFlag{
+	deleteImageFlag := &cli.StringFlag{
+		Name:     "image",
+		Aliases:  []string{"i"},
+		Usage:    "Image name to delete ie r0mdau/nodejs, or glob of image names listed from the catalog ie \"ci/*\"",
+		Required: true,
+	}
+	imageRegexFlag := &cli.BoolFlag{
+		Name:  "image-regex",
+		Usage: "Use -i as a regular expression on the image names listed from the catalog ie \"^ci/\"",
+	}
+	tagFlag := &cli.GenericFlag{
 		Name:    "tag",
 		Aliases: []string{"t"},
-		Usage:   "Image version tag to delete, regex possible ie \"master-*\", priority for semver",
+		Value:   &patternList{},
+		Usage:   "Image version tag to delete, glob possible ie \"master-*\" or \"*-master\", priority for semver, repeatable",
+	}
+	regexFlag := &cli.BoolFlag{
+		Name:  "regex",
+		Usage: "Use -t as a full regular expression, the (?P<version>...) named group is used for sorting",
+	}
+	excludeFlag := &cli.StringSliceFlag{
+		Name:    "exclude",
+		Aliases: []string{"e"},
+		Usage:   "Tag glob to never delete, repeatable, added to built-in latest, stable, prod-* and release tags",
+	}
+	noBuiltinProtectFlag := &cli.BoolFlag{
+		Name:  "no-builtin-protect",
+		Usage: "Don't protect latest, stable, prod-* and release tags, only the --exclude globs",
+	}
+	constraintFlag := &cli.StringFlag{
+		Name:    "constraint",
+		Aliases: []string{"c"},
+		Usage:   "Semver constraint on the tag version ie \"<2.0.0\" or \">=1.0, <1.5\", non semver tags are ignored, all tags without -t",
+	}
+	whereFlag := &cli.StringFlag{
+		Name:  "where",
+		Usage: "Policy expression a tag must also satisfy ie \"age(created) > 90d && !release(version)\", all tags without -t",
+	}
+	olderThanFlag := &cli.StringFlag{
+		Name:  "older-than",
+		Usage: "Only select tags whose image was created more than this age ago ie 30d, one extra request per matched tag",
+	}
+	sortFlag := &cli.StringFlag{
+		Name:  "sort",
+		Value: filter.SortSemver,
+		Usage: "Tag sort strategy for --keep, one of " + strings.Join(filter.SortStrategies, ", "),
+	}
+	keepPerFlag := &cli.StringFlag{
+		Name:  "keep-per",
+		Usage: "Apply --keep to each semver release line, one of " + strings.Join(filter.KeepPerLines, ", "),
+	}
+	supersededFlag := &cli.BoolFlag{
+		Name:  "delete-superseded-prereleases",
+		Usage: "Also delete pre-releases like 1.2.0-rc.1 once the final release 1.2.0 is matched",
+	}
+	keepPrereleasesFlag := &cli.IntFlag{
+		Name:  "keep-prereleases",
+		Usage: "Also delete matched pre-releases except the newest N, final releases are never affected",
+	}
+	groupFlag := &cli.BoolFlag{
+		Name:  "group-by-prefix",
+		Usage: "Split tags in <prefix>-<semver> and apply --keep to each prefix group, all tags without -t",
+	}
+	staleFlag := &cli.StringFlag{
+		Name:  "delete-stale-groups",
+		Usage: "Delete a whole prefix group when its newest tag is older than this age ie 30d, implies --group-by-prefix",
 	}
-	keepFlag := &cli.IntFlag{
+	goneBranchesFlag := &cli.BoolFlag{
+		Name:  "delete-gone-branches",
+		Usage: "Also delete tags whose branch prefix is no longer a git branch, or whose commit SHA is unreachable with --git-repo",
+	}
+	gitRepoFlag := &cli.StringFlag{
+		Name:  "git-repo",
+		Usage: "Path of a local git repository, fetch it first to know the remote branches and commits",
+	}
+	gitRefsFlag := &cli.StringFlag{
+		Name:  "git-refs",
+		Usage: "File holding the output of git for-each-ref, only branches are checked",
+	}
+	keepFlag := &cli.IntSliceFlag{
 		Name:    "keep",
 		Aliases: []string{"k"},
-		Value:   0,
-		Usage:   "Number of tags to keep, to combine with -t",
+		Usage:   "Number of tags to keep, to combine with -t, repeatable to pair with each -t",
 	}
 	numberFlag := &cli.IntFlag{
 		Name:  "n",
@@ -59,6 +135,107 @@ func CreateApp() *cli.App {
 		Name:  "insecure",
 		Usage: "Disable TLS cert verification",
 	}
+	destUrlFlag := &cli.StringFlag{
+		Name:     "dest-url",
+		Aliases:  []string{"d"},
+		Usage:    "Destination registry url, can be the same as --url",
+		Required: true,
+	}
+	configFlag := &cli.StringFlag{
+		Name:     "config",
+		Usage:    "YAML policy file with the registry and the rules to apply",
+		Required: true,
+	}
+	yesFlag := &cli.BoolFlag{
+		Name:  "yes",
+		Usage: "Delete without confirmation, ie from a cron",
+	}
+	webhookFlag := &cli.StringSliceFlag{
+		Name:  "webhook",
+		Usage: "Webhook url to post the summary of the deletion to, repeatable",
+	}
+	webhookFormatFlag := &cli.StringFlag{
+		Name:  "webhook-format",
+		Value: notify.FormatGeneric,
+		Usage: "Payload of the webhooks, one of " + strings.Join(notify.Formats, ", "),
+	}
+	webhookTemplateFlag := &cli.StringFlag{
+		Name:  "webhook-template",
+		Usage: "File of a Go text/template rendering the webhook body, or the Slack text",
+	}
+	webhookRetriesFlag := &cli.IntFlag{
+		Name:  "webhook-retries",
+		Value: defaultWebhookRetries,
+		Usage: "Number of retries of a failed webhook post",
+	}
+	webhookTimeoutFlag := &cli.DurationFlag{
+		Name:  "webhook-timeout",
+		Value: defaultWebhookTimeout,
+		Usage: "Timeout of each webhook post",
+	}
+	planURLFlag := &cli.StringFlag{
+		Name:    "url",
+		Aliases: []string{"u"},
+		Usage:   "Registry url, with credentials if any, defaults to the registry of the plan",
+	}
+	outFlag := &cli.StringFlag{
+		Name:    "out",
+		Aliases: []string{"o"},
+		Usage:   "File to save the plan to, - for stdout",
+	}
+	jsonFlag := &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the result of each deleted tag as JSON on stdout",
+	}
+	explainFlag := &cli.BoolFlag{
+		Name:  "explain",
+		Usage: "With --dryrun, print why each tag of the image is kept or deleted as a table, as JSON with --json",
+	}
+	auditLogFlag := &cli.StringFlag{
+		Name:  "audit-log",
+		Usage: "Append a JSON line per deleted tag to this file, before and after the delete request, - for stdout",
+	}
+	auditMaxSizeFlag := &cli.IntFlag{
+		Name:  "audit-max-size",
+		Value: 100,
+		Usage: "Size in MB of the audit log before it is rotated, 0 to never rotate",
+	}
+	auditBackupsFlag := &cli.IntFlag{
+		Name:  "audit-backups",
+		Value: 10,
+		Usage: "Number of rotated audit logs kept",
+	}
+	auditOperatorFlag := &cli.StringFlag{
+		Name:  "audit-operator",
+		Value: defaultOperator(),
+		Usage: "Operator named in the audit log",
+	}
+	metricsListenFlag := &cli.StringFlag{
+		Name:  "metrics-listen",
+		Usage: "Address serving Prometheus metrics on /metrics while the command runs ie :9090",
+	}
+	listenFlag := &cli.StringFlag{
+		Name:  "listen",
+		Usage: "Address serving the run history as JSON on /runs ie :8080",
+	}
+	apiListenFlag := &cli.StringFlag{
+		Name:  "listen",
+		Value: ":8080",
+		Usage: "Address of the API",
+	}
+	tokenFileFlag := &cli.StringFlag{
+		Name:     "token-file",
+		Usage:    "File of the bearer tokens accepted by the API, one per line after the name of its holder",
+		Required: true,
+	}
+	strictFlag := &cli.BoolFlag{
+		Name:  "strict",
+		Usage: "Also fail on warnings: conflicting, unreachable and dangerous rules",
+	}
+	destImageFlag := &cli.StringFlag{
+		Name:  "dest-image",
+		Usage: "Destination image name, defaults to --image",
+	}
 
 	app.Commands = []*cli.Command{
 		{
@@ -82,17 +259,182 @@ func CreateApp() *cli.App {
 		},
 		{
 			Name:   "delete",
-			Usage:  "Delete all specified tags for your image",
+			Usage:  "Delete all specified tags for your image, or for every image matching a glob or regex",
 			Action: deleteImage,
 			Flags: []cli.Flag{
 				urlFlag,
+				deleteImageFlag,
+				imageRegexFlag,
+				numberFlag,
+				tagFlag,
+				regexFlag,
+				constraintFlag,
+				whereFlag,
+				olderThanFlag,
+				sortFlag,
+				keepFlag,
+				keepPerFlag,
+				supersededFlag,
+				keepPrereleasesFlag,
+				groupFlag,
+				staleFlag,
+				goneBranchesFlag,
+				gitRepoFlag,
+				gitRefsFlag,
+				excludeFlag,
+				noBuiltinProtectFlag,
+				dryrunFlag,
+				insecureFlag,
+				metricsListenFlag,
+				webhookFlag,
+				webhookFormatFlag,
+				webhookTemplateFlag,
+				webhookRetriesFlag,
+				webhookTimeoutFlag,
+				auditLogFlag,
+				auditMaxSizeFlag,
+				auditBackupsFlag,
+				auditOperatorFlag,
+				jsonFlag,
+				explainFlag,
+			},
+		},
+		{
+			Name:   "copy",
+			Usage:  "Copy all specified tags for your image to another registry, same selection as delete",
+			Action: copyImage,
+			Flags: []cli.Flag{
+				urlFlag,
+				destUrlFlag,
 				imageFlag,
+				destImageFlag,
 				tagFlag,
+				regexFlag,
+				constraintFlag,
+				whereFlag,
+				olderThanFlag,
+				sortFlag,
 				keepFlag,
+				keepPerFlag,
+				supersededFlag,
+				keepPrereleasesFlag,
+				groupFlag,
+				staleFlag,
+				goneBranchesFlag,
+				gitRepoFlag,
+				gitRefsFlag,
 				dryrunFlag,
 				insecureFlag,
 			},
 		},
+		{
+			Name:   "run",
+			Usage:  "Plan and run every rule of a YAML policy file in one pass",
+			Action: runConfig,
+			Flags: []cli.Flag{
+				configFlag,
+				dryrunFlag,
+				yesFlag,
+				metricsListenFlag,
+				auditLogFlag,
+				auditMaxSizeFlag,
+				auditBackupsFlag,
+				auditOperatorFlag,
+				jsonFlag,
+			},
+		},
+		{
+			Name:   "plan",
+			Usage:  "Save the tags every rule of a YAML policy file would delete with their digests, to review them",
+			Action: savePlan,
+			Flags: []cli.Flag{
+				configFlag,
+				outFlag,
+			},
+		},
+		{
+			Name:      "apply",
+			Usage:     "Delete the tags of a saved plan still resolving to their planned digest, without confirmation",
+			ArgsUsage: "PLAN_FILE",
+			Action:    applyPlan,
+			Flags: []cli.Flag{
+				planURLFlag,
+				insecureFlag,
+				metricsListenFlag,
+				auditLogFlag,
+				auditMaxSizeFlag,
+				auditBackupsFlag,
+				auditOperatorFlag,
+				jsonFlag,
+			},
+		},
+		{
+			Name:   "daemon",
+			Usage:  "Run every rule of a YAML policy file on its schedule until interrupted",
+			Action: runDaemon,
+			Flags: []cli.Flag{
+				configFlag,
+				dryrunFlag,
+				listenFlag,
+				metricsListenFlag,
+				auditLogFlag,
+				auditMaxSizeFlag,
+				auditBackupsFlag,
+				auditOperatorFlag,
+			},
+		},
+		{
+			Name:   "serve",
+			Usage:  "Serve a REST API to list images, plan and run cleanups",
+			Action: serveAPI,
+			Flags: []cli.Flag{
+				urlFlag,
+				insecureFlag,
+				numberFlag,
+				apiListenFlag,
+				tokenFileFlag,
+				metricsListenFlag,
+				auditLogFlag,
+				auditMaxSizeFlag,
+				auditBackupsFlag,
+				auditOperatorFlag,
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "Check YAML policy files",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "validate",
+					Usage:  "Report invalid, conflicting, unreachable and dangerous rules, fails on errors",
+					Action: validateConfig,
+					Flags: []cli.Flag{
+						configFlag,
+						strictFlag,
+					},
+				},
+				{
+					Name:   "schema",
+					Usage:  "Print the JSON Schema of policy files for editor completion",
+					Action: printConfigSchema,
+				},
+			},
+		},
+		{
+			Name:  "policy",
+			Usage: "Check the decisions of YAML policy files",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "test",
+					Usage:     "Run the rules on fixture files of tags without registry and report unexpected decisions",
+					ArgsUsage: "FIXTURE...",
+					Action:    testPolicy,
+					Flags: []cli.Flag{
+						configFlag,
+					},
+				},
+			},
+		},
 	}
 
 	return app
@@ -104,7 +446,7 @@ func verifyRegistryVersion(registry registry.Registry) {
 }
 
 func printRepositoriesList(c *cli.Context) error {
-	registry := registry.NewRegistry(c.String("url"), c.Bool("insecure"))
+	registry := newRegistry(c.String("url"), c.Bool("insecure"))
 	verifyRegistryVersion(registry)
 
 	repositories, err := registry.ListRepositories(c.Int("n"))
@@ -116,7 +458,7 @@ func printRepositoriesList(c *cli.Context) error {
 }
 
 func printImageTagsList(c *cli.Context) error {
-	registry := registry.NewRegistry(c.String("url"), c.Bool("insecure"))
+	registry := newRegistry(c.String("url"), c.Bool("insecure"))
 	verifyRegistryVersion(registry)
 
 	imageTags, err := registry.ListImageTags(c.String("image"))
@@ -128,22 +470,45 @@ func printImageTagsList(c *cli.Context) error {
 }
 
 func deleteImage(c *cli.Context) error {
-	registry := registry.NewRegistry(c.String("url"), c.Bool("insecure"))
+	if c.Bool("explain") && !c.Bool("dryrun") {
+		return errors.New("--explain needs --dryrun")
+	}
+	rule, err := flagRule(c)
+	exit(err)
+	policy, err := newPolicy(rule)
+	exit(err)
+	pattern, several, err := imagePattern(c.String("image"), c.Bool("image-regex"))
+	exit(err)
+	webhooks, err := flagWebhooks(c)
+	exit(err)
+	// sizes are fetched before deletes to report the reclaimed bytes
+	stats.sizes = stats.sizes || len(webhooks) > 0
+	exit(openAuditLog(c))
+	defer auditLog.Close()
+
+	serveMetrics(c)
+	registry := newRegistry(c.String("url"), c.Bool("insecure"))
 	verifyRegistryVersion(registry)
 
+	if several {
+		return deleteImages(c, registry, rule, policy, pattern, webhooks)
+	}
+
 	cliImage := c.String("image")
-	cliTag := c.String("tag")
 	dryrun := c.Bool("dryrun")
-	keep := c.Int("keep")
 
 	registryResponse, err := registry.ListImageTags(cliImage)
 	exit(err)
 
-	tagsToDelete := registryResponse.GetImage().Tags
-	if cliTag != "" {
-		tagsToDelete, err = filter.MatchAndSortImageTags(registryResponse.GetImage().Tags, cliTag)
-		exit(err)
-		tagsToDelete = tagsToDelete[:len(tagsToDelete)-keep]
+	tags := registryResponse.GetImage().Tags
+	if c.Bool("explain") {
+		printExplanations(explainTags(registry, rule, policy, cliImage, tags), c.Bool("json"))
+		return nil
+	}
+	tagsToDelete, protected := policy.selectTags(registrySource{registry}, cliImage, tags)
+	stats.countSelection(cliImage, len(tags), len(tagsToDelete))
+	for _, p := range protected {
+		fmt.Fprintf(os.Stderr, "Protected %s:%s by rule \"%s\"\n", cliImage, p.Tag, p.Rule)
 	}
 
 	if dryrun {
@@ -154,39 +519,143 @@ func deleteImage(c *cli.Context) error {
 	}
 
 	if confirm("Are you sure to delete these tags ? (maybe try --dryrun first)") {
-		numJobs := len(tagsToDelete)
-		jobs := make(chan string, numJobs)
-		results := make(chan string, numJobs)
+		start := time.Now()
+		plan := imagePlan{Rule: flagLabel(rule), Image: cliImage, Tags: tagsToDelete, Protected: protectedTags(protected)}
+		plans, results := []imagePlan{plan}, []deleteResult{deleteTags(registry, plan)}
+		return finishDelete(c, "delete", start, plans, results, webhooks)
+	}
+	return nil
+}
 
-		for w := 0; w < workers; w++ {
-			go wDelete(registry, cliImage, jobs, results)
-		}
-		for _, tagToDelete := range tagsToDelete {
-			jobs <- tagToDelete
-		}
-		close(jobs)
-		for a := 0; a < numJobs; a++ {
-			<-results
+// deleteImages applies the tag selection of the delete command to every
+// image of the catalog matching pattern.
+func deleteImages(c *cli.Context, registry registry.Registry, rule config.Rule, policy policy, pattern filter.Pattern, webhooks []notify.Webhook) error {
+	images, err := catalogImages(registry, pattern, c.Int("n"))
+	exit(err)
+	if len(images) == 0 {
+		return fmt.Errorf("no image of the catalog matches %s", c.String("image"))
+	}
+
+	if c.Bool("explain") {
+		var explanations []tagExplanation
+		for _, image := range images {
+			registryResponse, err := registry.ListImageTags(image)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Can't list tags of %s, %s\n", image, err.Error())
+				continue
+			}
+			explanations = append(explanations, explainTags(registry, rule, policy, image, registryResponse.GetImage().Tags)...)
 		}
-		fmt.Fprintf(os.Stderr, "Total of %d tags deleted.\n", len(tagsToDelete))
+		printExplanations(explanations, c.Bool("json"))
+		return nil
+	}
+
+	plans := planImages(registry, policy, flagLabel(rule), images)
+	total := 0
+	for _, plan := range plans {
+		total += len(plan.Tags)
+		fmt.Fprintf(os.Stderr, "Planned %d tags of %s.\n", len(plan.Tags), plan.Image)
+	}
+
+	if c.Bool("dryrun") {
+		output, _ := json.Marshal(plans)
+		fmt.Println(string(output))
+		fmt.Fprintf(os.Stderr, "Dryrun, it should delete %d tags in %d of %d images.\n", total, len(plans), len(images))
+		return nil
+	}
+
+	if total > 0 && confirm(fmt.Sprintf("Are you sure to delete %d tags in %d images ? (maybe try --dryrun first)", total, len(plans))) {
+		start := time.Now()
+		return finishDelete(c, "delete", start, plans, deletePlans(registry, plans), webhooks)
 	}
 	return nil
 }
 
-func wDelete(registry registry.Registry, image string, jobs <-chan string, results chan<- string) {
-	for tag := range jobs {
+// finishDelete reports the results of plans, with --json on stdout too,
+// and fails when some tags may still be in the registry.
+func finishDelete(c *cli.Context, command string, start time.Time, plans []imagePlan, results []deleteResult, webhooks []notify.Webhook) error {
+	_, err := printDeleteSummary(plans, results)
+	if c.Bool("json") {
+		printResults(plans, results)
+	}
+	if err == nil {
+		stats.succeeded()
+	}
+	sendNotifications(webhooks, runSummary(command, start, plans, results))
+	return err
+}
+
+// deleteTags deletes the tags of a plan.
+func deleteTags(registry registry.Registry, plan imagePlan) deleteResult {
+	numJobs := len(plan.Tags)
+	jobs := make(chan int, numJobs)
+	results := make(chan int, numJobs)
+	tags := make([]tagResult, numJobs)
+
+	for w := 0; w < workers; w++ {
+		go wDelete(registry, plan, tags, jobs, results)
+	}
+	for i := range plan.Tags {
+		jobs <- i
+	}
+	close(jobs)
+	for a := 0; a < numJobs; a++ {
+		<-results
+	}
+	var result deleteResult
+	for _, tag := range tags {
+		result.add(tag)
+	}
+	return result
+}
+
+// wDelete deletes the tags of plan at the indexes of jobs, stores their
+// result in tags and sends their index to results.
+func wDelete(registry registry.Registry, plan imagePlan, tags []tagResult, jobs <-chan int, results chan<- int) {
+	image := plan.Image
+	for i := range jobs {
+		tag := plan.Tags[i]
+		if !plan.Until.IsZero() && !time.Now().Before(plan.Until) {
+			fmt.Fprintf(os.Stderr, "Skipped %s:%s, maintenance window ended\n", image, tag)
+			tags[i] = tagResult{Tag: tag, Status: tagSkipped, Error: fmt.Sprintf("%s:%s skipped, maintenance window ended", image, tag)}
+			results <- i
+			continue
+		}
 		digest, errGet := registry.GetDigestFromManifest(image, tag)
 		if errGet != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", errGet.Error())
-			results <- tag
+			tags[i] = newTagResult(tag, "", 0, errGet)
+			if tags[i].failure() {
+				stats.failed.Add(1, image)
+			}
+			results <- i
 			continue
 		}
+		if plan.Digests != nil && digest != plan.Digests[i] {
+			fmt.Fprintf(os.Stderr, "Skipped %s:%s, digest changed from %s to %s\n", image, tag, plan.Digests[i], digest)
+			tags[i] = tagResult{Tag: tag, Digest: digest, Status: tagDrifted, Error: fmt.Sprintf("%s:%s digest changed from %s", image, tag, plan.Digests[i])}
+			results <- i
+			continue
+		}
+		var size int64
+		if stats.sizes {
+			if info, err := registry.GetImageInfo(image, digest); err == nil {
+				size = info.Size
+			}
+		}
 		fmt.Fprintf(os.Stderr, "Deleting %s:%s\n", image, tag)
-		errDel := registry.DeleteImage(image, tag, digest)
+		errDel := auditedDelete(registry, plan, tag, digest)
+		tags[i] = newTagResult(tag, digest, size, errDel)
 		if errDel != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", errGet.Error())
+			fmt.Fprintf(os.Stderr, "%s\n", errDel.Error())
+			if tags[i].failure() {
+				stats.failed.Add(1, image)
+			}
+		} else {
+			stats.deleted.Add(1, image)
+			stats.reclaimed.Add(float64(size), image)
 		}
-		results <- tag
+		results <- i
 	}
 }
 
@@ -230,7 +230,64 @@ func TestCommandDeleteRequiredFlagAppRunBehavior(t *testing.T) {
 		},
 		{
 			testCase:        "valid_case_with_maximum_required_flag_on_command_delete",
-			appRunInput:     []string{"myCLI", "delete", "--url", "http://localhost", "--image", "r0mdau/nodejs", "--tag", "1.0.0", "--keep", "1", "--dryrun", "--insecure"},
+			appRunInput:     []string{"myCLI", "delete", "--url", "http://localhost", "--image", "r0mdau/nodejs", "--tag", "1.0.0", "--regex", "--sort", "natural", "--keep", "1", "--keep-per", "minor", "--delete-superseded-prereleases", "--keep-prereleases", "3", "--exclude", "keep-*", "--exclude", "v*", "--dryrun", "--insecure"},
+			expectedAnError: false,
+		},
+		{
+			testCase:        "valid_case_with_multiple_tag_keep_pairs_on_command_delete",
+			appRunInput:     []string{"myCLI", "delete", "--url", "http://localhost", "--image", "r0mdau/nodejs", "--tag", "master-*", "--keep", "5", "--tag", "develop-*", "--keep", "3", "--tag", "pr-*", "--keep", "0"},
+			expectedAnError: false,
+		},
+	}
+
+	assertAppBehaviour(t, tdata)
+}
+
+func TestCommandCopyRequiredFlagAppRunBehavior(t *testing.T) {
+	tdata := []struct {
+		testCase        string
+		appRunInput     []string
+		expectedAnError bool
+	}{
+		{
+			testCase:        "error_case_empty_input_with_required_flag_on_command_copy",
+			appRunInput:     []string{"myCLI", "copy"},
+			expectedAnError: true,
+		},
+		{
+			testCase:        "error_case_missing_dest_url_required_flag_on_command_copy",
+			appRunInput:     []string{"myCLI", "copy", "--url", "http://localhost", "--image", "r0mdau/nodejs"},
+			expectedAnError: true,
+		},
+		{
+			testCase:        "valid_case_with_minimum_required_flag_on_command_copy",
+			appRunInput:     []string{"myCLI", "copy", "--url", "http://localhost", "--dest-url", "http://remote", "--image", "r0mdau/nodejs"},
+			expectedAnError: false,
+		},
+		{
+			testCase:        "valid_case_with_maximum_required_flag_on_command_copy",
+			appRunInput:     []string{"myCLI", "copy", "-u", "http://localhost", "-d", "http://remote", "-i", "r0mdau/nodejs", "--dest-image", "archive/nodejs", "-t", "master-*", "-k", "2", "--keep-per", "major", "--dryrun", "--insecure"},
+			expectedAnError: false,
+		},
+	}
+
+	assertAppBehaviour(t, tdata)
+}
+
+func TestCommandRunRequiredFlagAppRunBehavior(t *testing.T) {
+	tdata := []struct {
+		testCase        string
+		appRunInput     []string
+		expectedAnError bool
+	}{
+		{
+			testCase:        "error_case_missing_config_required_flag_on_command_run",
+			appRunInput:     []string{"myCLI", "run", "--dryrun"},
+			expectedAnError: true,
+		},
+		{
+			testCase:        "valid_case_with_maximum_required_flag_on_command_run",
+			appRunInput:     []string{"myCLI", "run", "--config", "policy.yaml", "--dryrun", "--yes"},
 			expectedAnError: false,
 		},
 	}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"os"
+)
+
+func copyImage(c *cli.Context) error {
+	rule, err := flagRule(c)
+	exit(err)
+	policy, err := newPolicy(rule)
+	exit(err)
+
+	source := newRegistry(c.String("url"), c.Bool("insecure"))
+	verifyRegistryVersion(source)
+	destination := source
+	if c.String("dest-url") != c.String("url") {
+		destination = newRegistry(c.String("dest-url"), c.Bool("insecure"))
+		verifyRegistryVersion(destination)
+	}
+
+	cliImage := c.String("image")
+	destImage := c.String("dest-image")
+	if destImage == "" {
+		destImage = cliImage
+	}
+
+	registryResponse, err := source.ListImageTags(cliImage)
+	exit(err)
+
+	tags := registryResponse.GetImage().Tags
+	tagsToCopy, _ := filter.Select(tags, policy.resolve(registrySource{source}, cliImage, tags), nil)
+
+	if c.Bool("dryrun") {
+		output, _ := json.Marshal(tagsToCopy)
+		fmt.Println(string(output))
+		fmt.Fprintf(os.Stderr, "Dryrun, it should copy image : \"%s\" to \"%s\" with %d tags.\n", cliImage, destImage, len(tagsToCopy))
+		return nil
+	}
+
+	return copyTags(source, destination, cliImage, destImage, tagsToCopy)
+}
+
+// copyTags copies the tags of image to destImage, failing when any tag
+// could not be copied.
+func copyTags(source, destination registry.Registry, image, destImage string, tags []string) error {
+	numJobs := len(tags)
+	jobs := make(chan string, numJobs)
+	results := make(chan error, numJobs)
+
+	for w := 0; w < workers; w++ {
+		go wCopy(source, destination, image, destImage, jobs, results)
+	}
+	for _, tagToCopy := range tags {
+		jobs <- tagToCopy
+	}
+	close(jobs)
+	copied := 0
+	for a := 0; a < numJobs; a++ {
+		if <-results == nil {
+			copied++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Total of %d/%d tags copied.\n", copied, numJobs)
+	if copied < numJobs {
+		return fmt.Errorf("%d of %d tags failed to be copied", numJobs-copied, numJobs)
+	}
+	return nil
+}
+
+func wCopy(source, destination registry.Registry, image, destImage string, jobs <-chan string, results chan<- error) {
+	for tag := range jobs {
+		fmt.Fprintf(os.Stderr, "Copying %s:%s to %s:%s\n", image, tag, destImage, tag)
+		err := registry.CopyTag(source, destination, image, destImage, tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+		results <- err
+	}
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCopyTags(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["master-1.0.0","master-1.1.0"]`,
+	})
+	r := registry.NewRegistry(server.URL, false)
+
+	require.NoError(t, copyTags(r, r, "r0mdau/nodejs", "archive/nodejs", nil))
+	// the test registry serves no manifest
+	require.EqualError(t, copyTags(r, r, "r0mdau/nodejs", "archive/nodejs", []string{"master-1.0.0", "master-1.1.0"}), "2 of 2 tags failed to be copied")
+}
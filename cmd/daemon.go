@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/internal/schedule"
+	"github.com/urfave/cli/v2"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemon starts the runs of the schedule one at a time, inside the
+// maintenance window if any, and keeps the last ones in memory.
+type daemon struct {
+	window *schedule.Window
+	// work runs the rules and counts what it did in run
+	work    func(run *runRecord) error
+	history *runHistory
+
+	mutex   sync.Mutex
+	running bool
+	wg      sync.WaitGroup
+}
+
+// trigger starts a run at now in the background, or records it as skipped
+// when the previous one is still running or now is outside the window.
+func (d *daemon) trigger(now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	run := d.history.next(now)
+	switch {
+	case d.running:
+		run.Reason = "previous run still in progress"
+	case d.window != nil && !d.window.Contains(now):
+		run.Reason = "outside maintenance window " + d.window.String()
+	}
+	if run.Reason != "" {
+		run.Status, run.End = runSkipped, &now
+		fmt.Fprintf(os.Stderr, "Skipped run %d, %s.\n", run.ID, run.Reason)
+		d.history.record(run)
+		return
+	}
+
+	d.running = true
+	d.history.record(run)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.history.finish(run, d.work(&run))
+
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		d.running = false
+	}()
+}
+
+// wait returns once the current run, if any, is over.
+func (d *daemon) wait() {
+	d.wg.Wait()
+}
+
+// ServeHTTP lists the history as JSON on /runs.
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/runs" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.history.list())
+}
+
+// daemonSchedule compiles the schedule section of the config file.
+func daemonSchedule(s config.Schedule) (schedule.Schedule, time.Duration, *schedule.Window, error) {
+	var next schedule.Schedule
+	switch {
+	case s.Cron != "":
+		cron, err := schedule.ParseCron(s.Cron)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		next = cron
+	case s.Every != "":
+		every, err := filter.ParseAge(s.Every)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		next = schedule.Interval(every)
+	default:
+		return nil, 0, nil, errors.New("schedule.cron or schedule.every is required")
+	}
+
+	var jitter time.Duration
+	if s.Jitter != "" {
+		var err error
+		if jitter, err = filter.ParseAge(s.Jitter); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+	var window *schedule.Window
+	if s.Window != "" {
+		parsed, err := schedule.ParseWindow(s.Window)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		window = &parsed
+	}
+	return next, jitter, window, nil
+}
+
+// runDaemon plans and runs the rules of the config file on its schedule
+// until interrupted, without confirmation.
+func runDaemon(c *cli.Context) error {
+	cfg, err := config.Load(c.String("config"))
+	exit(err)
+	next, jitter, window, err := daemonSchedule(cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.String("config"), err)
+	}
+	// policies are compiled again on each run to read the git refs and
+	// history of the time, this only checks the rules before starting
+	_, err = configPolicies(cfg)
+	exit(err)
+	webhooks, err := configWebhooks(cfg.Notify)
+	exit(err)
+	// sizes are fetched before deletes to report the reclaimed bytes
+	stats.sizes = stats.sizes || len(webhooks) > 0
+	exit(openAuditLog(c))
+	defer auditLog.Close()
+
+	serveMetrics(c)
+	registry := newRegistry(cfg.Registry.URL, cfg.Registry.Insecure)
+	verifyRegistryVersion(registry)
+
+	dryrun := c.Bool("dryrun")
+	d := &daemon{
+		window:  window,
+		history: &runHistory{size: cfg.Schedule.History},
+		work: func(run *runRecord) error {
+			var plans []imagePlan
+			policies, err := configPolicies(cfg)
+			if err == nil {
+				plans, err = planRules(registry, cfg, policies)
+			}
+			if err != nil {
+				summary := runSummary("daemon", run.Start, nil, nil)
+				summary.Errors = []string{err.Error()}
+				sendNotifications(webhooks, summary)
+				return err
+			}
+			run.Images = len(plans)
+			for _, plan := range plans {
+				run.Planned += len(plan.Tags)
+			}
+			if dryrun {
+				fmt.Fprintf(os.Stderr, "Dryrun, it should delete %d tags in %d images.\n", run.Planned, run.Images)
+				return nil
+			}
+			if window != nil {
+				until := window.Until(run.Start)
+				for i := range plans {
+					plans[i].Until = until
+				}
+			}
+			results := deletePlans(registry, plans)
+			for _, result := range results {
+				run.Skipped += result.count(tagSkipped)
+			}
+			run.Deleted, err = printDeleteSummary(plans, results)
+			sendNotifications(webhooks, runSummary("daemon", run.Start, plans, results))
+			if err != nil {
+				return err
+			}
+			stats.succeeded()
+			return nil
+		},
+	}
+
+	if listen := c.String("listen"); listen != "" {
+		server := &http.Server{Addr: listen, Handler: d}
+		go func() {
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		at := next.Next(time.Now())
+		if at.IsZero() {
+			return errors.New("the schedule never runs")
+		}
+		if jitter > 0 {
+			at = at.Add(time.Duration(random.Int63n(int64(jitter))))
+		}
+		fmt.Fprintf(os.Stderr, "Next run at %s.\n", at.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+			d.trigger(time.Now())
+		case <-signals:
+			timer.Stop()
+			fmt.Fprintf(os.Stderr, "Stopping, waiting for the current run.\n")
+			d.wait()
+			return nil
+		}
+	}
+}
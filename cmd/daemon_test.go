@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/schedule"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2021, 3, 15, hour, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("Runs never overlap", func(t *testing.T) {
+		release := make(chan bool)
+		d := &daemon{history: &runHistory{size: 10}, work: func(run *runRecord) error {
+			<-release
+			run.Planned, run.Deleted = 3, 3
+			return nil
+		}}
+		d.trigger(at(1))
+		d.trigger(at(2))
+		release <- true
+		d.wait()
+
+		runs := d.history.list()
+		require.Len(t, runs, 2)
+		require.Equal(t, 2, runs[0].ID)
+		require.Equal(t, runSkipped, runs[0].Status)
+		require.Equal(t, "previous run still in progress", runs[0].Reason)
+		require.Equal(t, 1, runs[1].ID)
+		require.Equal(t, runSucceeded, runs[1].Status)
+		require.Equal(t, 3, runs[1].Deleted)
+		require.NotNil(t, runs[1].End)
+	})
+
+	t.Run("Runs outside the window are skipped", func(t *testing.T) {
+		window, err := schedule.ParseWindow("01:00-05:00")
+		require.NoError(t, err)
+		d := &daemon{history: &runHistory{size: 10}, window: &window, work: func(run *runRecord) error {
+			return errors.New("registry unreachable")
+		}}
+		d.trigger(at(12))
+		d.trigger(at(3))
+		d.wait()
+
+		runs := d.history.list()
+		require.Equal(t, runFailed, runs[0].Status)
+		require.Equal(t, "registry unreachable", runs[0].Reason)
+		require.Equal(t, runSkipped, runs[1].Status)
+		require.Equal(t, "outside maintenance window 01:00-05:00", runs[1].Reason)
+	})
+
+	t.Run("History keeps the last runs", func(t *testing.T) {
+		d := &daemon{history: &runHistory{size: 2}, work: func(run *runRecord) error {
+			return nil
+		}}
+		for hour := 1; hour <= 4; hour++ {
+			d.trigger(at(hour))
+			d.wait()
+		}
+
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/runs", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var runs []runRecord
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &runs))
+		require.Len(t, runs, 2)
+		require.Equal(t, 4, runs[0].ID)
+		require.Equal(t, 3, runs[1].ID)
+
+		recorder = httptest.NewRecorder()
+		d.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestDeleteUntil(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["pr-1","pr-2"]`,
+	})
+	r := newRegistry(server.URL, false)
+
+	plans := []imagePlan{
+		{Image: "r0mdau/nodejs", Tags: []string{"pr-1"}, Until: time.Now().Add(time.Hour)},
+		{Image: "r0mdau/nodejs", Tags: []string{"pr-2"}, Until: time.Now()},
+	}
+	results := deletePlans(r, plans)
+	require.Equal(t, 1, results[0].Deleted)
+	require.Equal(t, []tagResult{{
+		Tag:    "pr-2",
+		Status: tagSkipped,
+		Error:  "r0mdau/nodejs:pr-2 skipped, maintenance window ended",
+	}}, results[1].Tags)
+	deleted, err := printDeleteSummary(plans, results)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+}
+
+func TestDaemonSchedule(t *testing.T) {
+	next, jitter, window, err := daemonSchedule(config.Schedule{Every: "6h", Jitter: "10m", Window: "22:00-02:00"})
+	require.NoError(t, err)
+	require.Equal(t, schedule.Interval(6*time.Hour), next)
+	require.Equal(t, 10*time.Minute, jitter)
+	require.Equal(t, &schedule.Window{Start: 22 * 60, End: 2 * 60}, window)
+
+	_, _, window, err = daemonSchedule(config.Schedule{Cron: "@daily"})
+	require.NoError(t, err)
+	require.Nil(t, window)
+
+	_, _, _, err = daemonSchedule(config.Schedule{})
+	require.EqualError(t, err, "schedule.cron or schedule.every is required")
+	_, _, _, err = daemonSchedule(config.Schedule{Cron: "daily"})
+	require.EqualError(t, err, "invalid cron expression \"daily\", expected 5 fields, got 1")
+}
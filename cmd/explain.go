@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// tagExplanation tells why delete would keep or delete a tag.
+type tagExplanation struct {
+	Image  string `json:"image"`
+	Tag    string `json:"tag"`
+	Delete bool   `json:"delete"`
+	// Reason is one of the reasons of filter.Explain
+	Reason  string `json:"reason"`
+	Version string `json:"version,omitempty"`
+	// Rank is the sort position among the matches, 1 being the newest
+	Rank int `json:"rank,omitempty"`
+	// Keep and KeepPer are those of the selector which decided
+	Keep    *int   `json:"keep,omitempty"`
+	KeepPer string `json:"keep_per,omitempty"`
+	// Rule is the --tag which decided, or the rule protecting the tag
+	Rule   string `json:"rule,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	// SharedDigest are the other tags with the same digest, deleting a
+	// manifest removes every tag of its digest so they share the decision
+	SharedDigest []string `json:"shared_digest,omitempty"`
+}
+
+// explainTags explains the decision of policy on every tag of image, rule
+// being the flags it was compiled from.
+func explainTags(registry registry.Registry, rule config.Rule, policy policy, image string, tags []string) []tagExplanation {
+	source := registrySource{registry}
+	selectors := policy.resolve(source, image, tags)
+	decisions := filter.Explain(tags, selectors, policy.protect)
+
+	digests := tagDigests(source, image, tags)
+	var selected []string
+	for _, d := range decisions {
+		if d.Delete {
+			selected = append(selected, d.Tag)
+		}
+	}
+	_, shared := protectSharedDigests(tags, digests, selected, nil)
+	sharedBy := make(map[string]string)
+	for _, p := range shared {
+		sharedBy[p.Tag] = p.Rule
+	}
+	byDigest := make(map[string][]string)
+	for i, d := range decisions {
+		if rule, ok := sharedBy[d.Tag]; ok {
+			decisions[i].Delete, decisions[i].Reason, decisions[i].Rank, decisions[i].Rule = false, filter.ReasonProtected, 0, rule
+		}
+		if digest, ok := digests[d.Tag]; ok {
+			byDigest[digest] = append(byDigest[digest], d.Tag)
+		}
+	}
+
+	explanations := make([]tagExplanation, len(decisions))
+	for i, d := range decisions {
+		explanations[i] = tagExplanation{
+			Image:   image,
+			Tag:     d.Tag,
+			Delete:  d.Delete,
+			Reason:  d.Reason,
+			Version: d.Version,
+			Rank:    d.Rank,
+			Rule:    d.Rule,
+			Digest:  digests[d.Tag],
+		}
+		for _, other := range byDigest[digests[d.Tag]] {
+			if other != d.Tag {
+				explanations[i].SharedDigest = append(explanations[i].SharedDigest, other)
+			}
+		}
+		if d.Selector < 0 || d.Reason == filter.ReasonProtected {
+			continue
+		}
+		keep := selectors[d.Selector].Keep
+		explanations[i].Keep, explanations[i].KeepPer = &keep, selectors[d.Selector].KeepPer
+		explanations[i].Rule = `--tag "*"`
+		if d.Selector < len(rule.Tags) {
+			explanations[i].Rule = fmt.Sprintf("--tag %q", rule.Tags[d.Selector].Pattern)
+		}
+	}
+	return explanations
+}
+
+// reasonText describes the reason of an explanation in the table.
+func (e tagExplanation) reasonText() string {
+	switch e.Reason {
+	case filter.ReasonNewest, filter.ReasonOlder:
+		if *e.Keep < 0 {
+			return "keep all"
+		}
+		text := fmt.Sprintf("one of the %d newest", *e.Keep)
+		if e.Reason == filter.ReasonOlder {
+			text = fmt.Sprintf("older than the %d newest", *e.Keep)
+		}
+		if e.KeepPer != "" {
+			text += " of its " + e.KeepPer + " line"
+		}
+		return text
+	case filter.ReasonPrerelease:
+		return "pre-release"
+	case filter.ReasonAll:
+		return "no tag selection"
+	}
+	return e.Reason
+}
+
+// printExplanations prints explanations as a table, or as JSON.
+func printExplanations(explanations []tagExplanation, asJSON bool) {
+	if asJSON {
+		if explanations == nil {
+			explanations = []tagExplanation{}
+		}
+		output, _ := json.Marshal(explanations)
+		fmt.Println(string(output))
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "IMAGE\tTAG\tDECISION\tREASON\tVERSION\tRANK\tRULE\tSHARED DIGEST")
+	for _, e := range explanations {
+		decision, rank := "keep", ""
+		if e.Delete {
+			decision = "delete"
+		}
+		if e.Rank > 0 {
+			rank = strconv.Itoa(e.Rank)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Image, e.Tag, decision, e.reasonText(), e.Version, rank, e.Rule, strings.Join(e.SharedDigest, ", "))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExplainTags(t *testing.T) {
+	digests := map[string]string{
+		"latest":       "sha256:b",
+		"master-0.9.0": "sha256:e",
+		"master-1.0.0": "sha256:b",
+		"master-1.1.0": "sha256:a",
+		"master-1.2.0": "sha256:c",
+		"pr-1":         "sha256:d",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, "/v2/r0mdau/nodejs/manifests/")
+		if digest, ok := digests[tag]; ok && r.Method == http.MethodHead {
+			w.Header().Set("Docker-Content-Digest", digest)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	keep := 1
+	rule := config.Rule{Tags: []config.Tag{{Pattern: "master-*", Keep: &keep}}, Exclude: []string{"master-1.2.0"}}
+	policy, err := newPolicy(rule)
+	require.NoError(t, err)
+
+	explanations := explainTags(registry.NewRegistry(server.URL, false), rule, policy, "r0mdau/nodejs", []string{"latest", "master-0.9.0", "master-1.0.0", "master-1.1.0", "master-1.2.0", "pr-1"})
+	require.Equal(t, []tagExplanation{
+		{Image: "r0mdau/nodejs", Tag: "latest", Reason: filter.ReasonNotMatched, Digest: "sha256:b", SharedDigest: []string{"master-1.0.0"}},
+		{Image: "r0mdau/nodejs", Tag: "master-0.9.0", Delete: true, Reason: filter.ReasonOlder, Version: "0.9.0", Rank: 3, Keep: &keep, Rule: `--tag "master-*"`, Digest: "sha256:e"},
+		{Image: "r0mdau/nodejs", Tag: "master-1.0.0", Reason: filter.ReasonProtected, Version: "1.0.0", Rule: "shared digest with latest", Digest: "sha256:b", SharedDigest: []string{"latest"}},
+		{Image: "r0mdau/nodejs", Tag: "master-1.1.0", Reason: filter.ReasonNewest, Version: "1.1.0", Rank: 1, Keep: &keep, Rule: `--tag "master-*"`, Digest: "sha256:a"},
+		{Image: "r0mdau/nodejs", Tag: "master-1.2.0", Reason: filter.ReasonProtected, Rule: "exclude:master-1.2.0", Digest: "sha256:c"},
+		{Image: "r0mdau/nodejs", Tag: "pr-1", Reason: filter.ReasonNotMatched, Digest: "sha256:d"},
+	}, explanations)
+
+	require.Equal(t, "older than the 1 newest", explanations[1].reasonText())
+	require.Equal(t, "protected", explanations[2].reasonText())
+	require.Equal(t, "one of the 1 newest", explanations[3].reasonText())
+	require.Equal(t, "not matched", explanations[0].reasonText())
+}
+
+func TestExplainNeedsDryrun(t *testing.T) {
+	app := CreateApp()
+	err := app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "--explain"})
+	require.EqualError(t, err, "--explain needs --dryrun")
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+	runSkipped   = "skipped"
+)
+
+// runRecord is a run of the daemon or of the API server as kept in memory.
+type runRecord struct {
+	ID      int        `json:"id"`
+	Plan    string     `json:"plan,omitempty"`
+	Start   time.Time  `json:"start"`
+	End     *time.Time `json:"end,omitempty"`
+	Status  string     `json:"status"`
+	Reason  string     `json:"reason,omitempty"`
+	Images  int        `json:"images"`
+	Planned int        `json:"planned"`
+	Deleted int        `json:"deleted"`
+	// Skipped are the planned tags left when the maintenance window ended
+	Skipped int `json:"skipped,omitempty"`
+	// Operator is the holder of the API token which started the run
+	Operator string `json:"operator,omitempty"`
+}
+
+// runHistory keeps the last size runs, safe for concurrent use.
+type runHistory struct {
+	size   int
+	mutex  sync.Mutex
+	runs   []runRecord
+	lastID int
+}
+
+// next returns a running run starting at now with a new ID, to record.
+func (h *runHistory) next(now time.Time) runRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastID++
+	return runRecord{ID: h.lastID, Start: now, Status: runRunning}
+}
+
+// record adds or updates run, dropping the oldest runs.
+func (h *runHistory) record(run runRecord) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i := range h.runs {
+		if h.runs[i].ID == run.ID {
+			h.runs[i] = run
+			return
+		}
+	}
+	h.runs = append(h.runs, run)
+	if len(h.runs) > h.size {
+		h.runs = h.runs[len(h.runs)-h.size:]
+	}
+}
+
+// finish records the end of run, failed with err if not nil.
+func (h *runHistory) finish(run runRecord, err error) {
+	end := time.Now()
+	run.Status, run.End = runSucceeded, &end
+	if err != nil {
+		run.Status, run.Reason = runFailed, err.Error()
+	}
+	fmt.Fprintf(os.Stderr, "Run %d %s.\n", run.ID, run.Status)
+	h.record(run)
+}
+
+// list returns the kept runs, newest first.
+func (h *runHistory) list() []runRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	runs := make([]runRecord, len(h.runs))
+	for i, run := range h.runs {
+		runs[len(runs)-1-i] = run
+	}
+	return runs
+}
+
+// get returns the run with the given ID if still kept.
+func (h *runHistory) get(id int) (runRecord, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, run := range h.runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return runRecord{}, false
+}
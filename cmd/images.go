@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"os"
+	"strings"
+	"sync"
+)
+
+// imageWorkers is the number of images planned or cleaned at once, each one
+// with its own tag workers.
+const imageWorkers = 4
+
+// imagePattern compiles the image name of the delete command, a regular
+// expression with regex or a glob, and tells if it names several images.
+func imagePattern(image string, regex bool) (filter.Pattern, bool, error) {
+	if regex {
+		pattern, err := filter.NewRegexPattern(image)
+		return pattern, true, err
+	}
+	if !strings.ContainsAny(image, "*?") {
+		return filter.Pattern{}, false, nil
+	}
+	pattern, err := filter.NewGlobPattern(image)
+	return pattern, true, err
+}
+
+// catalogImages lists the n first repositories of the catalog matching
+// pattern.
+func catalogImages(registry registry.Registry, pattern filter.Pattern, n int) ([]string, error) {
+	repositories, err := registry.ListRepositories(n)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, image := range repositories.GetRepository().List {
+		if _, ok := pattern.Match(image); ok {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// planImages lists the tags of each image and selects those to delete by
+// the policy with the given label concurrently, plans are in the order of
+// images and images whose tags can't be listed are reported and left out.
+func planImages(registry registry.Registry, policy policy, label string, images []string) []imagePlan {
+	plans := make([]imagePlan, len(images))
+	jobs := make(chan int, len(images))
+	var wg sync.WaitGroup
+
+	for w := 0; w < imageWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				image := images[i]
+				plans[i].Rule, plans[i].Image = label, image
+				registryResponse, err := registry.ListImageTags(image)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Can't list tags of %s, %s\n", image, err.Error())
+					continue
+				}
+				tags := registryResponse.GetImage().Tags
+				tagsToDelete, protected := policy.selectTags(registrySource{registry}, image, tags)
+				stats.countSelection(image, len(tags), len(tagsToDelete))
+				for _, p := range protected {
+					fmt.Fprintf(os.Stderr, "Protected %s:%s by rule \"%s\"\n", image, p.Tag, p.Rule)
+				}
+				plans[i].Tags, plans[i].Protected = tagsToDelete, protectedTags(protected)
+			}
+		}()
+	}
+	for i := range images {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var planned []imagePlan
+	for _, plan := range plans {
+		if len(plan.Tags) > 0 {
+			planned = append(planned, plan)
+		}
+	}
+	return planned
+}
+
+// deletePlans runs the plans concurrently and returns what each one did.
+func deletePlans(registry registry.Registry, plans []imagePlan) []deleteResult {
+	results := make([]deleteResult, len(plans))
+	jobs := make(chan int, len(plans))
+	var wg sync.WaitGroup
+
+	for w := 0; w < imageWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = deleteTags(registry, plans[i])
+			}
+		}()
+	}
+	for i := range plans {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func protectedTags(protected []filter.Protected) []string {
+	var tags []string
+	for _, p := range protected {
+		tags = append(tags, p.Tag)
+	}
+	return tags
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestImagePattern(t *testing.T) {
+	_, several, err := imagePattern("r0mdau/nodejs", false)
+	require.NoError(t, err)
+	require.False(t, several)
+
+	tdata := []struct {
+		image    string
+		regex    bool
+		expected []string
+	}{
+		{"r0mdau/*", false, []string{"r0mdau/nodejs", "r0mdau/php"}},
+		{"*/?o", false, []string{"other/go"}},
+		{"^r0mdau/(php|go)$", true, []string{"r0mdau/php"}},
+		{"js", true, []string{"r0mdau/nodejs"}},
+	}
+	for _, test := range tdata {
+		t.Run(test.image, func(t *testing.T) {
+			pattern, several, err := imagePattern(test.image, test.regex)
+			require.NoError(t, err)
+			require.True(t, several)
+
+			var matched []string
+			for _, image := range []string{"r0mdau/nodejs", "r0mdau/php", "other/go"} {
+				if _, ok := pattern.Match(image); ok {
+					matched = append(matched, image)
+				}
+			}
+			require.Equal(t, test.expected, matched)
+		})
+	}
+
+	_, _, err = imagePattern("(", true)
+	require.Error(t, err)
+}
+
+func TestDeleteImages(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["latest","master-1.0.0","master-1.1.0","master-1.2.0"]`,
+		"r0mdau/php":    `["master-2.0.0","master-2.1.0"]`,
+		"other/go":      `["master-1.0.0","master-2.0.0"]`,
+	})
+	r := registry.NewRegistry(server.URL, false)
+	pattern, _, err := imagePattern("r0mdau/*", false)
+	require.NoError(t, err)
+
+	images, err := catalogImages(r, pattern, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{"r0mdau/nodejs", "r0mdau/php"}, images)
+
+	keep := 1
+	policy, err := newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "master-*", Keep: &keep}}})
+	require.NoError(t, err)
+	plans := planImages(r, policy, "test", append(images, "r0mdau/missing"))
+	require.Equal(t, []imagePlan{
+		{Rule: "test", Image: "r0mdau/nodejs", Tags: []string{"master-1.0.0", "master-1.1.0"}},
+		{Rule: "test", Image: "r0mdau/php", Tags: []string{"master-2.0.0"}},
+	}, plans)
+
+	results := deletePlans(r, plans)
+	require.Len(t, results, 2)
+	require.Equal(t, []tagResult{
+		{Tag: "master-1.0.0", Digest: "sha256:master-1.0.0", Status: tagDeleted},
+		{Tag: "master-1.1.0", Digest: "sha256:master-1.1.0", Status: tagDeleted},
+	}, results[0].Tags)
+	require.Equal(t, 1, results[1].Deleted)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/metrics"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cleanupMetrics counts what the commands do, served on /metrics with
+// --metrics-listen.
+type cleanupMetrics struct {
+	set         *metrics.Set
+	scanned     *metrics.Family
+	planned     *metrics.Family
+	deleted     *metrics.Family
+	failed      *metrics.Family
+	reclaimed   *metrics.Family
+	requests    *metrics.Family
+	lastSuccess *metrics.Family
+	// sizes makes the delete workers fetch the size of each image to count
+	// reclaimed bytes, one extra request per tag
+	sizes bool
+}
+
+var stats = newCleanupMetrics()
+
+func newCleanupMetrics() *cleanupMetrics {
+	set := metrics.NewSet()
+	return &cleanupMetrics{
+		set:         set,
+		scanned:     set.Counter("clean_registry_tags_scanned_total", "Tags listed in the registry", "repository"),
+		planned:     set.Counter("clean_registry_tags_planned_total", "Tags selected for deletion", "repository"),
+		deleted:     set.Counter("clean_registry_tags_deleted_total", "Tags deleted", "repository"),
+		failed:      set.Counter("clean_registry_tags_failed_total", "Tags whose deletion failed", "repository"),
+		reclaimed:   set.Counter("clean_registry_bytes_reclaimed_total", "Size of the deleted images, layers shared with other images included", "repository"),
+		requests:    set.Histogram("clean_registry_request_duration_seconds", "Latency of the registry requests, status 0 on transport errors", metrics.DefaultBuckets, "endpoint", "method", "status"),
+		lastSuccess: set.Gauge("clean_registry_last_success_timestamp_seconds", "Unix time of the end of the last run without failure"),
+	}
+}
+
+func (m *cleanupMetrics) observeRequest(endpoint, method string, status int, duration time.Duration) {
+	m.requests.Observe(duration.Seconds(), endpoint, method, strconv.Itoa(status))
+}
+
+// countSelection counts the tags listed and selected in image.
+func (m *cleanupMetrics) countSelection(image string, scanned, planned int) {
+	m.scanned.Add(float64(scanned), image)
+	m.planned.Add(float64(planned), image)
+}
+
+// succeeded records the end of a run without failure.
+func (m *cleanupMetrics) succeeded() {
+	m.lastSuccess.Set(float64(time.Now().Unix()))
+}
+
+// newRegistry connects to the registry with its requests measured.
+func newRegistry(url string, insecure bool) registry.Registry {
+	return registry.NewRegistry(url, insecure).Observe(stats.observeRequest)
+}
+
+// serveMetrics serves /metrics in the background when --metrics-listen is
+// set, for as long as the command runs.
+func serveMetrics(c *cli.Context) {
+	listen := c.String("metrics-listen")
+	if listen == "" {
+		return
+	}
+	stats.sizes = true
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", stats.set)
+	go func() {
+		log.Fatal(http.ListenAndServe(listen, mux))
+	}()
+}
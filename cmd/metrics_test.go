@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCleanupMetrics(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"other/go": `["pr-1","pr-2"]`,
+	})
+	previous := stats
+	stats = newCleanupMetrics()
+	t.Cleanup(func() { stats = previous })
+	r := newRegistry(server.URL, false)
+
+	results := deletePlans(r, []imagePlan{{Image: "other/go", Tags: []string{"pr-1", "pr-2"}}})
+	require.Equal(t, 2, results[0].Deleted)
+	require.Equal(t, float64(2), stats.deleted.Value("other/go"))
+	results = deletePlans(r, []imagePlan{{Image: "other/missing", Tags: []string{"pr-1"}}})
+	require.Equal(t, []string{"404 Not Found : Error while getting digest from manifest for: other/missing:pr-1"}, results[0].Errors)
+	require.Equal(t, float64(0), stats.failed.Value("other/missing"))
+	results = deletePlans(newRegistry("http://127.0.0.1:1", false), []imagePlan{{Image: "other/go", Tags: []string{"pr-1"}}})
+	require.Equal(t, 1, results[0].failed())
+	require.Equal(t, float64(1), stats.failed.Value("other/go"))
+
+	stats.succeeded()
+	var output bytes.Buffer
+	_, err := stats.set.WriteTo(&output)
+	require.NoError(t, err)
+	require.Contains(t, output.String(), `clean_registry_request_duration_seconds_count{endpoint="manifests",method="DELETE",status="202"} 2`)
+	require.Contains(t, output.String(), `clean_registry_request_duration_seconds_count{endpoint="manifests",method="HEAD",status="404"} 1`)
+	require.Contains(t, output.String(), "clean_registry_last_success_timestamp_seconds ")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/notify"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// flagWebhooks returns the webhooks of the delete command flags.
+func flagWebhooks(c *cli.Context) ([]notify.Webhook, error) {
+	urls := c.StringSlice("webhook")
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	hook := config.Webhook{Format: c.String("webhook-format")}
+	if path := c.String("webhook-template"); path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hook.Template = string(content)
+	}
+	retries := c.Int("webhook-retries")
+	hook.Retries = &retries
+	hook.Timeout = c.Duration("webhook-timeout").String()
+
+	var hooks []config.Webhook
+	for _, url := range urls {
+		hook.URL = url
+		hooks = append(hooks, hook)
+	}
+	return configWebhooks(hooks)
+}
+
+// configWebhooks checks the webhooks of a config file and applies their
+// defaults.
+func configWebhooks(hooks []config.Webhook) ([]notify.Webhook, error) {
+	var webhooks []notify.Webhook
+	for i, hook := range hooks {
+		webhook := notify.Webhook{
+			URL:      hook.URL,
+			Format:   hook.Format,
+			Template: hook.Template,
+			Retries:  defaultWebhookRetries,
+			Timeout:  defaultWebhookTimeout,
+		}
+		if webhook.Format == "" {
+			webhook.Format = notify.FormatGeneric
+		}
+		if webhook.Format != notify.FormatGeneric && webhook.Format != notify.FormatSlack {
+			return nil, fmt.Errorf("notify[%d]: unknown format \"%s\"", i, hook.Format)
+		}
+		if _, err := notify.ParseTemplate(hook.Template); err != nil {
+			return nil, fmt.Errorf("notify[%d]: %v", i, err)
+		}
+		if hook.Retries != nil {
+			webhook.Retries = *hook.Retries
+		}
+		if hook.Timeout != "" {
+			timeout, err := time.ParseDuration(hook.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("notify[%d]: %v", i, err)
+			}
+			webhook.Timeout = timeout
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// runSummary sums up a run of plans which started at start, protected tags
+// and those left when the maintenance window ended being skipped.
+func runSummary(command string, start time.Time, plans []imagePlan, results []deleteResult) notify.Summary {
+	host, _ := os.Hostname()
+	summary := notify.Summary{Command: command, Host: host, Start: start, End: time.Now()}
+	for i, plan := range plans {
+		summary.Add(notify.ImageSummary{
+			Image:          plan.Image,
+			Deleted:        results[i].Deleted,
+			Skipped:        len(plan.Protected) + results[i].count(tagSkipped),
+			Failed:         results[i].failed(),
+			BytesReclaimed: results[i].Bytes,
+		}, results[i].Errors...)
+	}
+	return summary
+}
+
+// sendNotifications posts summary to the webhooks, their errors are only
+// reported.
+func sendNotifications(webhooks []notify.Webhook, summary notify.Summary) {
+	for _, err := range notify.Notify(webhooks, summary) {
+		fmt.Fprintf(os.Stderr, "Can't notify, %s\n", err.Error())
+	}
+}
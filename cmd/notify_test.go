@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/notify"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfigWebhooks(t *testing.T) {
+	one := 1
+	webhooks, err := configWebhooks([]config.Webhook{
+		{URL: "http://hooks.example.com/a"},
+		{URL: "http://hooks.example.com/b", Format: notify.FormatSlack, Template: "{{.Deleted}} deleted", Retries: &one, Timeout: "2s"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []notify.Webhook{
+		{URL: "http://hooks.example.com/a", Format: notify.FormatGeneric, Retries: 3, Timeout: 10 * time.Second},
+		{URL: "http://hooks.example.com/b", Format: notify.FormatSlack, Template: "{{.Deleted}} deleted", Retries: 1, Timeout: 2 * time.Second},
+	}, webhooks)
+
+	_, err = configWebhooks([]config.Webhook{{URL: "http://hooks.example.com", Format: "teams"}})
+	require.EqualError(t, err, "notify[0]: unknown format \"teams\"")
+	_, err = configWebhooks([]config.Webhook{{URL: "http://hooks.example.com", Template: "{{.Deleted"}})
+	require.EqualError(t, err, "notify[0]: template: webhook:1: unclosed action")
+}
+
+func TestRunSummary(t *testing.T) {
+	start := time.Now()
+	summary := runSummary("run", start, []imagePlan{
+		{Image: "r0mdau/nodejs", Tags: []string{"pr-1", "pr-2"}, Protected: []string{"latest"}},
+		{Image: "r0mdau/php", Tags: []string{"pr-1", "pr-2"}},
+	}, []deleteResult{
+		{Deleted: 2, Bytes: 2048, Tags: []tagResult{{Tag: "pr-1", Status: tagDeleted}, {Tag: "pr-2", Status: tagDeleted}}},
+		{
+			Errors: []string{"401 Unauthorized : Error while deleting image:tag : r0mdau/php:pr-1"},
+			Tags:   []tagResult{{Tag: "pr-1", Status: tagUnauthorized}, {Tag: "pr-2", Status: tagSkipped}},
+		},
+	})
+
+	require.Equal(t, "run", summary.Command)
+	require.Equal(t, start, summary.Start)
+	require.Equal(t, []notify.ImageSummary{
+		{Image: "r0mdau/nodejs", Deleted: 2, Skipped: 1, BytesReclaimed: 2048},
+		{Image: "r0mdau/php", Skipped: 1, Failed: 1},
+	}, summary.Images)
+	require.Equal(t, 2, summary.Deleted)
+	require.Equal(t, 2, summary.Skipped)
+	require.Equal(t, 1, summary.Failed)
+	require.Equal(t, []string{"401 Unauthorized : Error while deleting image:tag : r0mdau/php:pr-1"}, summary.Errors)
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// plannedTag is a tag of a saved plan with the digest it resolved to.
+type plannedTag struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+// plannedImage holds the tags a rule deletes in one image of a saved plan.
+type plannedImage struct {
+	Rule      string       `json:"rule,omitempty"`
+	Image     string       `json:"image"`
+	Tags      []plannedTag `json:"tags"`
+	Protected []string     `json:"protected,omitempty"`
+	// Unresolved are the tags whose digest couldn't be resolved, never
+	// applied
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// planFile is a plan saved by the plan command to be reviewed, then run by
+// apply. Registry has no credentials.
+type planFile struct {
+	Registry string         `json:"registry"`
+	Insecure bool           `json:"insecure,omitempty"`
+	Created  time.Time      `json:"created"`
+	Images   []plannedImage `json:"images"`
+}
+
+// savePlan plans every rule of a policy file like run and saves the tags
+// with their digests.
+func savePlan(c *cli.Context) error {
+	cfg, err := config.Load(c.String("config"))
+	exit(err)
+	policies, err := configPolicies(cfg)
+	exit(err)
+
+	registry := newRegistry(cfg.Registry.URL, cfg.Registry.Insecure)
+	verifyRegistryVersion(registry)
+
+	plans, err := planRules(registry, cfg, policies)
+	exit(err)
+	file := planFile{
+		Registry: registryName(registry),
+		Insecure: cfg.Registry.Insecure,
+		Created:  time.Now().UTC(),
+		Images:   []plannedImage{},
+	}
+	total, unresolved := 0, 0
+	for _, plan := range plans {
+		image := resolveDigests(registry, plan)
+		total += len(image.Tags)
+		unresolved += len(image.Unresolved)
+		file.Images = append(file.Images, image)
+	}
+
+	output, _ := json.MarshalIndent(file, "", "  ")
+	if out := c.String("out"); out != "" && out != "-" {
+		exit(ioutil.WriteFile(out, append(output, '\n'), 0644))
+	} else {
+		fmt.Println(string(output))
+	}
+	fmt.Fprintf(os.Stderr, "Total of %d tags planned in %d images.\n", total, len(file.Images))
+	if unresolved > 0 {
+		fmt.Fprintf(os.Stderr, "Total of %d tags unresolved, not planned.\n", unresolved)
+	}
+	return nil
+}
+
+// resolveDigests resolves the digest of each tag of plan concurrently, tags
+// which can't be resolved are reported and recorded as unresolved.
+func resolveDigests(registry registry.Registry, plan imagePlan) plannedImage {
+	digests := make([]string, len(plan.Tags))
+	jobs := make(chan int, len(plan.Tags))
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				digest, err := registry.GetDigestFromManifest(plan.Image, plan.Tags[i])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					continue
+				}
+				digests[i] = digest
+			}
+		}()
+	}
+	for i := range plan.Tags {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	image := plannedImage{Rule: plan.Rule, Image: plan.Image, Tags: []plannedTag{}, Protected: plan.Protected}
+	for i, tag := range plan.Tags {
+		if digests[i] == "" {
+			image.Unresolved = append(image.Unresolved, tag)
+			continue
+		}
+		image.Tags = append(image.Tags, plannedTag{tag, digests[i]})
+	}
+	return image
+}
+
+// loadPlan reads a plan saved by the plan command.
+func loadPlan(path string) (planFile, error) {
+	var file planFile
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+	if file.Registry == "" {
+		return file, fmt.Errorf("%s: registry is required", path)
+	}
+	for i, image := range file.Images {
+		for j, tag := range image.Tags {
+			if tag.Tag == "" || tag.Digest == "" {
+				return file, fmt.Errorf("%s: images[%d].tags[%d] needs a tag and a digest", path, i, j)
+			}
+		}
+	}
+	return file, nil
+}
+
+// plans are the images of the plan to delete, each tag only if it still
+// resolves to its planned digest.
+func (f planFile) plans() []imagePlan {
+	plans := make([]imagePlan, len(f.Images))
+	for i, image := range f.Images {
+		plans[i] = imagePlan{Rule: image.Rule, Image: image.Image, Protected: image.Protected}
+		for _, tag := range image.Tags {
+			plans[i].Tags = append(plans[i].Tags, tag.Tag)
+			plans[i].Digests = append(plans[i].Digests, tag.Digest)
+		}
+	}
+	return plans
+}
+
+// applyPlan deletes the tags of a saved plan without confirmation, skipping
+// those whose digest changed since.
+func applyPlan(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("give the plan file to apply")
+	}
+	file, err := loadPlan(c.Args().First())
+	if err != nil {
+		return err
+	}
+	exit(openAuditLog(c))
+	defer auditLog.Close()
+
+	url := file.Registry
+	if c.IsSet("url") {
+		url = c.String("url")
+	}
+	serveMetrics(c)
+	registry := newRegistry(url, file.Insecure || c.Bool("insecure"))
+	verifyRegistryVersion(registry)
+
+	plans := file.plans()
+	total := 0
+	for _, plan := range plans {
+		total += len(plan.Tags)
+	}
+	fmt.Fprintf(os.Stderr, "Applying %d tags in %d images planned at %s.\n", total, len(plans), file.Created.Format(time.RFC3339))
+	start := time.Now()
+	return finishDelete(c, "apply", start, plans, deletePlans(registry, plans), nil)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlanFile(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["latest","pr-1","pr-2","pr-3"]`,
+	})
+	r := registry.NewRegistry(server.URL, false)
+
+	image := resolveDigests(r, imagePlan{Rule: "prs", Image: "r0mdau/nodejs", Tags: []string{"pr-1", "pr-2", "pr-3"}})
+	require.Equal(t, plannedImage{Rule: "prs", Image: "r0mdau/nodejs", Tags: []plannedTag{
+		{"pr-1", "sha256:pr-1"},
+		{"pr-2", "sha256:pr-2"},
+		{"pr-3", "sha256:pr-3"},
+	}}, image)
+
+	missing := resolveDigests(r, imagePlan{Image: "other/missing", Tags: []string{"pr-1"}})
+	require.Equal(t, plannedImage{Image: "other/missing", Tags: []plannedTag{}, Unresolved: []string{"pr-1"}}, missing)
+
+	// pr-2 is pushed again after the review
+	image.Tags[1].Digest = "sha256:old"
+	path := filepath.Join(t.TempDir(), "plan.json")
+	content, err := json.Marshal(planFile{Registry: server.URL, Created: time.Now(), Images: []plannedImage{image}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, content, 0644))
+
+	file, err := loadPlan(path)
+	require.NoError(t, err)
+	plans := file.plans()
+	require.Equal(t, []string{"sha256:pr-1", "sha256:old", "sha256:pr-3"}, plans[0].Digests)
+
+	results := deletePlans(r, plans)
+	require.Equal(t, 2, results[0].Deleted)
+	require.Equal(t, 0, results[0].failed())
+	require.Equal(t, tagResult{
+		Tag:    "pr-2",
+		Digest: "sha256:pr-2",
+		Status: tagDrifted,
+		Error:  "r0mdau/nodejs:pr-2 digest changed from sha256:old",
+	}, results[0].Tags[1])
+	deleted, err := printDeleteSummary(plans, results)
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+}
+
+func TestApplyPlan(t *testing.T) {
+	var mutex sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reference := strings.TrimPrefix(r.URL.Path, "/v2/r0mdau/nodejs/manifests/")
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.Method == http.MethodHead && reference == "pr-2":
+			// pr-2 is pushed again after the review
+			w.Header().Set("Docker-Content-Digest", "sha256:new")
+		case r.Method == http.MethodHead:
+			w.Header().Set("Docker-Content-Digest", "sha256:"+reference)
+		case r.Method == http.MethodDelete:
+			mutex.Lock()
+			deleted = append(deleted, reference)
+			mutex.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	content, err := json.Marshal(planFile{Registry: server.URL, Created: time.Now(), Images: []plannedImage{{
+		Image: "r0mdau/nodejs",
+		Tags:  []plannedTag{{"pr-1", "sha256:pr-1"}, {"pr-2", "sha256:pr-2"}},
+	}}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, content, 0644))
+
+	require.NoError(t, CreateApp().Run([]string{"", "apply", path}))
+	require.Equal(t, []string{"sha256:pr-1"}, deleted)
+}
+
+func TestLoadPlan(t *testing.T) {
+	dir := t.TempDir()
+	tdata := []struct {
+		content  string
+		expected string
+	}{
+		{`{"images":[]}`, "registry is required"},
+		{`{"registry":"https://r","images":[{"image":"a","tags":[{"tag":"v1"}]}]}`, "images[0].tags[0] needs a tag and a digest"},
+		{`{"registry":"https://r","image":"a"}`, `json: unknown field "image"`},
+	}
+	for i, test := range tdata {
+		path := filepath.Join(dir, "plan.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(test.content), 0644))
+		_, err := loadPlan(path)
+		require.EqualError(t, err, path+": "+test.expected, i)
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/expr"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"os"
+	"sync"
+	"time"
+)
+
+// tagSource provides what policies read about tags besides their names, the
+// registry or the fixtures of policy tests.
+type tagSource interface {
+	GetImageConfig(image, reference string) (registry.ImageConfig, error)
+	GetImageInfo(image, reference string) (registry.ImageInfo, error)
+	GetDigestFromManifest(image, reference string) (string, error)
+	Now() time.Time
+}
+
+// registrySource reads tags from the registry at the current time.
+type registrySource struct {
+	registry.Registry
+}
+
+func (registrySource) Now() time.Time {
+	return time.Now()
+}
+
+// policy is a compiled rule, ready to select the tags of any image.
+type policy struct {
+	selectors []filter.Selector
+	where     *expr.Expression
+	protect   []filter.Rule
+}
+
+func newPolicy(rule config.Rule) (policy, error) {
+	selectors, err := ruleSelectors(rule)
+	if err != nil {
+		return policy{}, err
+	}
+	where, err := whereExpression(rule.Where, rule.OlderThan)
+	if err != nil {
+		return policy{}, err
+	}
+	excludeRules, err := filter.NewExcludeRules(rule.Exclude)
+	if err != nil {
+		return policy{}, err
+	}
+	return policy{selectors, where, append(builtinRules(rule), excludeRules...)}, nil
+}
+
+// builtinRules are the built-in protect rules rule applies, release tags
+// being selected on purpose by release lines and constraints.
+func builtinRules(rule config.Rule) []filter.Rule {
+	if rule.NoBuiltinProtect {
+		return nil
+	}
+	var rules []filter.Rule
+	for _, builtin := range filter.DefaultProtectRules() {
+		if builtin.Name == filter.ReleaseRule && (rule.KeepPer != "" || rule.Constraint != "") {
+			continue
+		}
+		rules = append(rules, builtin)
+	}
+	return rules
+}
+
+// resolve returns the selectors of the policy ready for the tags of image.
+func (p policy) resolve(source tagSource, image string, tags []string) []filter.Selector {
+	selectors := append([]filter.Selector(nil), p.selectors...)
+	return resolveSelectors(source, image, tags, selectors, p.where)
+}
+
+// selectTags returns the tags of image to delete and the protected ones.
+func (p policy) selectTags(source tagSource, image string, tags []string) ([]string, []filter.Protected) {
+	selected, protected := filter.Select(tags, p.resolve(source, image, tags), p.protect)
+	if len(selected) == 0 {
+		return selected, protected
+	}
+	return protectSharedDigests(tags, tagDigests(source, image, tags), selected, protected)
+}
+
+// protectSharedDigests protects the selected tags sharing their digest with
+// a tag not selected, deleting a manifest removes every tag of its digest.
+func protectSharedDigests(tags []string, digests map[string]string, selected []string, protected []filter.Protected) ([]string, []filter.Protected) {
+	isSelected := make(map[string]bool)
+	for _, tag := range selected {
+		isSelected[tag] = true
+	}
+	kept := make(map[string]string)
+	for _, tag := range tags {
+		if digest := digests[tag]; digest != "" && !isSelected[tag] && kept[digest] == "" {
+			kept[digest] = tag
+		}
+	}
+
+	var remaining []string
+	for _, tag := range selected {
+		if other := kept[digests[tag]]; other != "" {
+			protected = append(protected, filter.Protected{Tag: tag, Rule: "shared digest with " + other})
+			continue
+		}
+		remaining = append(remaining, tag)
+	}
+	return remaining, protected
+}
+
+// tagDigests resolves the digest of each tag concurrently, tags which can't
+// be resolved are reported and left out.
+func tagDigests(source tagSource, image string, tags []string) map[string]string {
+	digests := make(map[string]string)
+	var mutex sync.Mutex
+	jobs := make(chan string, len(tags))
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tag := range jobs {
+				digest, err := source.GetDigestFromManifest(image, tag)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Can't get digest of %s:%s, %s\n", image, tag, err.Error())
+					continue
+				}
+				mutex.Lock()
+				digests[tag] = digest
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, tag := range tags {
+		jobs <- tag
+	}
+	close(jobs)
+	wg.Wait()
+	return digests
+}
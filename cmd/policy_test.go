@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPolicySelectTags(t *testing.T) {
+	keep := 0
+	source := fixtureSource{config.Fixture{Image: "r0mdau/nodejs", Tags: []config.FixtureTag{
+		{Tag: "latest", Digest: "sha256:a"},
+		{Tag: "1.0.0", Digest: "sha256:b"},
+		{Tag: "pr-1", Digest: "sha256:a"},
+		{Tag: "pr-2", Digest: "sha256:c"},
+		{Tag: "pr-3"},
+	}}}
+	tags := []string{"latest", "1.0.0", "pr-1", "pr-2", "pr-3"}
+
+	t.Run("Tags sharing the digest of a kept tag are protected", func(t *testing.T) {
+		policy, err := newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "*"}}, Keep: &keep})
+		require.NoError(t, err)
+		selected, protected := policy.selectTags(source, "r0mdau/nodejs", tags)
+		require.ElementsMatch(t, []string{"pr-2", "pr-3"}, selected)
+		require.Equal(t, []filter.Protected{
+			{Tag: "latest", Rule: "builtin:latest"},
+			{Tag: "1.0.0", Rule: "builtin:release"},
+			{Tag: "pr-1", Rule: "shared digest with latest"},
+		}, protected)
+	})
+
+	t.Run("Release lines select release tags", func(t *testing.T) {
+		one := 1
+		tags := []string{"latest", "1.0.0", "1.0.1", "2.0.0", "2.0.1", "2.1.0"}
+		releases := fixtureSource{config.Fixture{Image: "r0mdau/nodejs"}}
+		for _, tag := range tags {
+			releases.fixture.Tags = append(releases.fixture.Tags, config.FixtureTag{Tag: tag})
+		}
+		policy, err := newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "*"}}, Keep: &one, KeepPer: filter.KeepPerMajor})
+		require.NoError(t, err)
+		selected, protected := policy.selectTags(releases, "r0mdau/nodejs", tags)
+		require.ElementsMatch(t, []string{"1.0.0", "2.0.0", "2.0.1"}, selected)
+		require.Equal(t, []filter.Protected{{Tag: "latest", Rule: "builtin:latest"}}, protected)
+
+		policy, err = newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "*"}}, Keep: &one})
+		require.NoError(t, err)
+		selected, _ = policy.selectTags(releases, "r0mdau/nodejs", tags)
+		require.Empty(t, selected)
+	})
+
+	t.Run("Constraints select release tags", func(t *testing.T) {
+		tags := []string{"latest", "0.9.0", "1.0.0", "1.4.2", "1.4.3", "1.5.0", "master-1.1.0"}
+		releases := fixtureSource{config.Fixture{Image: "r0mdau/nodejs"}}
+		for _, tag := range tags {
+			releases.fixture.Tags = append(releases.fixture.Tags, config.FixtureTag{Tag: tag})
+		}
+		policy, err := newPolicy(config.Rule{Constraint: ">=1.0, <1.5, !=1.4.2"})
+		require.NoError(t, err)
+		selected, _ := policy.selectTags(releases, "r0mdau/nodejs", tags)
+		require.ElementsMatch(t, []string{"1.0.0", "1.4.3"}, selected)
+
+		policy, err = newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "master-*"}}, Constraint: "<2.0.0"})
+		require.NoError(t, err)
+		selected, _ = policy.selectTags(releases, "r0mdau/nodejs", tags)
+		require.Equal(t, []string{"master-1.1.0"}, selected)
+	})
+
+	t.Run("Built-in rules can be turned off", func(t *testing.T) {
+		policy, err := newPolicy(config.Rule{Tags: []config.Tag{{Pattern: "*"}}, Keep: &keep, NoBuiltinProtect: true, Exclude: []string{"pr-2"}})
+		require.NoError(t, err)
+		selected, protected := policy.selectTags(source, "r0mdau/nodejs", tags)
+		require.ElementsMatch(t, []string{"latest", "1.0.0", "pr-1", "pr-3"}, selected)
+		require.Equal(t, []filter.Protected{{Tag: "pr-2", Rule: "exclude:pr-2"}}, protected)
+	})
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"os"
+	"time"
+)
+
+// fixtureSource reads tags from a policy test fixture instead of a registry.
+type fixtureSource struct {
+	fixture config.Fixture
+}
+
+func (s fixtureSource) tag(image, reference string) (config.FixtureTag, error) {
+	for _, tag := range s.fixture.Tags {
+		if tag.Tag == reference && image == s.fixture.Image {
+			return tag, nil
+		}
+	}
+	return config.FixtureTag{}, fmt.Errorf("%s:%s is not in the fixture", image, reference)
+}
+
+func (s fixtureSource) GetImageConfig(image, reference string) (registry.ImageConfig, error) {
+	tag, err := s.tag(image, reference)
+	if err != nil {
+		return registry.ImageConfig{}, err
+	}
+	config := registry.ImageConfig{Created: tag.Created}
+	config.Config.Labels = tag.Labels
+	return config, nil
+}
+
+func (s fixtureSource) GetImageInfo(image, reference string) (registry.ImageInfo, error) {
+	tag, err := s.tag(image, reference)
+	if err != nil {
+		return registry.ImageInfo{}, err
+	}
+	return registry.ImageInfo{
+		Digest:   tag.Digest,
+		Size:     tag.Size,
+		Created:  tag.Created,
+		Labels:   tag.Labels,
+		Platform: tag.Platform,
+	}, nil
+}
+
+// GetDigestFromManifest returns the digest of the fixture tag, empty when
+// unset so that it shares it with no other tag.
+func (s fixtureSource) GetDigestFromManifest(image, reference string) (string, error) {
+	tag, err := s.tag(image, reference)
+	return tag.Digest, err
+}
+
+func (s fixtureSource) Now() time.Time {
+	if s.fixture.Now.IsZero() {
+		return time.Now()
+	}
+	return s.fixture.Now
+}
+
+// testPolicy plans the rules of the config file on the tags of each fixture
+// file, like the run command without registry, and reports the tags whose
+// decision isn't the expected one.
+func testPolicy(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return errors.New("give at least one fixture file")
+	}
+	cfg, err := config.Load(c.String("config"))
+	if err != nil {
+		return err
+	}
+	policies, err := configPolicies(cfg)
+	if err != nil {
+		return err
+	}
+
+	failed, mismatches := 0, 0
+	for _, path := range c.Args().Slice() {
+		fixture, err := config.LoadFixture(path)
+		if err != nil {
+			return err
+		}
+		failures, err := testFixture(cfg, policies, fixture)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, failure := range failures {
+			fmt.Printf("--- FAIL: %s: %s\n", path, failure)
+		}
+		if len(failures) > 0 {
+			fmt.Printf("FAIL\t%s\t%d of %d tags\n", path, len(failures), len(fixture.Tags))
+			failed++
+			mismatches += len(failures)
+		} else {
+			fmt.Printf("ok\t%s\t%d tags\n", path, len(fixture.Tags))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Total of %d mismatches in %d fixtures.\n", mismatches, c.NArg())
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures failed", failed, c.NArg())
+	}
+	return nil
+}
+
+// testFixture returns the mismatches between the decisions of the rules
+// matching the fixture image and the expected ones.
+func testFixture(cfg config.Config, policies []policy, fixture config.Fixture) ([]string, error) {
+	var tags []string
+	for _, tag := range fixture.Tags {
+		tags = append(tags, tag.Tag)
+	}
+
+	source := fixtureSource{fixture}
+	deletedBy := make(map[string]string)
+	protectedBy := make(map[string]string)
+	planned := make(map[string]bool)
+	for i, rule := range cfg.Rules {
+		pattern, err := filter.NewGlobPattern(rule.Image)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", rule.Label(i), err)
+		}
+		if _, ok := pattern.Match(fixture.Image); !ok {
+			continue
+		}
+		plan, protected := planImage(source, rule.Label(i), policies[i], fixture.Image, tags, planned)
+		for _, tag := range plan.Tags {
+			deletedBy[tag] = plan.Rule
+		}
+		for _, p := range protected {
+			if _, ok := protectedBy[p.Tag]; !ok {
+				protectedBy[p.Tag] = fmt.Sprintf("protected by rule \"%s\"", p.Rule)
+			}
+		}
+	}
+
+	var failures []string
+	for _, tag := range fixture.Tags {
+		rule, deleted := deletedBy[tag.Tag]
+		switch {
+		case deleted && tag.Expect == config.ExpectKeep:
+			failures = append(failures, fmt.Sprintf("%s:%s expected keep, got delete by %s", fixture.Image, tag.Tag, rule))
+		case !deleted && tag.Expect == config.ExpectDelete:
+			reason := "no rule deletes it"
+			if protected, ok := protectedBy[tag.Tag]; ok {
+				reason = protected
+			}
+			failures = append(failures, fmt.Sprintf("%s:%s expected delete, got keep, %s", fixture.Image, tag.Tag, reason))
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicyConfig = `
+registry:
+  url: http://localhost
+rules:
+  - name: branches
+    image: "r0mdau/*"
+    tags:
+      - pattern: "master-*"
+        keep: 1
+  - name: old
+    image: r0mdau/nodejs
+    tags:
+      - pattern: "pr-*"
+    where: age(created) > 30d
+    exclude: ["pr-hotfix"]
+`
+
+const testFixtureContent = `
+image: r0mdau/nodejs
+now: 2021-06-01T00:00:00Z
+tags:
+  - tag: master-1.0.0
+    expect: delete
+  - tag: master-1.1.0
+    expect: keep
+  - tag: pr-1
+    created: 2021-01-01T00:00:00Z
+    expect: delete
+  - tag: pr-2
+    created: 2021-05-30T00:00:00Z
+    expect: %s
+  - tag: pr-hotfix
+    created: 2021-01-01T00:00:00Z
+    expect: %s
+`
+
+func TestPolicyTest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	policyPath := write("policy.yaml", testPolicyConfig)
+	run := func(args ...string) error {
+		return newTestApp().Run(append([]string{"", "policy", "test", "--config", policyPath}, args...))
+	}
+
+	passing := write("passing.yaml", fmt.Sprintf(testFixtureContent, "keep", "keep"))
+	require.NoError(t, run(passing))
+
+	failing := write("failing.yaml", fmt.Sprintf(testFixtureContent, "delete", "delete"))
+	require.EqualError(t, run(passing, failing), "1 of 2 fixtures failed")
+	require.EqualError(t, run(), "give at least one fixture file")
+
+	cfg, err := config.Load(policyPath)
+	require.NoError(t, err)
+	policies, err := configPolicies(cfg)
+	require.NoError(t, err)
+	fixture, err := config.LoadFixture(failing)
+	require.NoError(t, err)
+	failures, err := testFixture(cfg, policies, fixture)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"r0mdau/nodejs:pr-2 expected delete, got keep, no rule deletes it",
+		"r0mdau/nodejs:pr-hotfix expected delete, got keep, protected by rule \"exclude:pr-hotfix\"",
+	}, failures)
+
+	fixture.Tags[1].Expect = config.ExpectDelete
+	fixture.Tags[2].Expect = config.ExpectKeep
+	failures, err = testFixture(cfg, policies, fixture)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"r0mdau/nodejs:master-1.1.0 expected delete, got keep, no rule deletes it",
+		"r0mdau/nodejs:pr-1 expected keep, got delete by rule \"old\"",
+		"r0mdau/nodejs:pr-2 expected delete, got keep, no rule deletes it",
+		"r0mdau/nodejs:pr-hotfix expected delete, got keep, protected by rule \"exclude:pr-hotfix\"",
+	}, failures)
+
+	// deleting pr-1 would delete latest too
+	fixture.Tags[2].Expect, fixture.Tags[2].Digest = config.ExpectDelete, "sha256:a"
+	fixture.Tags = append(fixture.Tags, config.FixtureTag{Tag: "latest", Digest: "sha256:a", Expect: config.ExpectKeep})
+	failures, err = testFixture(cfg, policies, fixture)
+	require.NoError(t, err)
+	require.Contains(t, failures, "r0mdau/nodejs:pr-1 expected delete, got keep, protected by rule \"shared digest with latest\"")
+}
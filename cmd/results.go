@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Outcomes of the deletion of a tag.
+const (
+	tagDeleted      = "deleted"
+	tagNotFound     = "not_found"
+	tagUnauthorized = "unauthorized"
+	tagUnsupported  = "unsupported"
+	tagFailed       = "failed"
+	// tagDrifted is a tag of a saved plan whose digest changed, left alone
+	tagDrifted = "drifted"
+	// tagSkipped is a tag left alone because the maintenance window ended
+	tagSkipped = "skipped"
+)
+
+// tagStatuses are the outcomes other than deleted in the order of the
+// summaries.
+var tagStatuses = []string{tagNotFound, tagDrifted, tagSkipped, tagUnauthorized, tagUnsupported, tagFailed}
+
+// tagResult is the outcome of the deletion of one tag.
+type tagResult struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest,omitempty"`
+	Status string `json:"status"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// newTagResult classifies err, the registry answering 405 when deletion
+// is disabled.
+func newTagResult(tag, digest string, size int64, err error) tagResult {
+	result := tagResult{Tag: tag, Digest: digest, Status: tagDeleted, Bytes: size}
+	if err == nil {
+		return result
+	}
+	result.Bytes, result.Error, result.Status = 0, err.Error(), tagFailed
+	var httpErr *registry.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusNotFound:
+			result.Status = tagNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			result.Status = tagUnauthorized
+		case http.StatusMethodNotAllowed:
+			result.Status = tagUnsupported
+		}
+	}
+	return result
+}
+
+// failure tells if the tag may still be in the registry by error, a tag not
+// found is already gone and drifted or skipped ones are left on purpose.
+func (r tagResult) failure() bool {
+	return r.Status != tagDeleted && r.Status != tagNotFound && r.Status != tagDrifted && r.Status != tagSkipped
+}
+
+// deleteResult is what deleteTags did in one image.
+type deleteResult struct {
+	Deleted int
+	// Bytes is the size of the deleted images when stats.sizes is set
+	Bytes  int64
+	Errors []string
+	// Tags are in the order of the plan
+	Tags []tagResult
+}
+
+// add counts the result of a tag.
+func (r *deleteResult) add(tag tagResult) {
+	r.Tags = append(r.Tags, tag)
+	if tag.Status == tagDeleted {
+		r.Deleted++
+		r.Bytes += tag.Bytes
+		return
+	}
+	r.Errors = append(r.Errors, tag.Error)
+}
+
+// count is the number of tags with status.
+func (r deleteResult) count(status string) int {
+	n := 0
+	for _, tag := range r.Tags {
+		if tag.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// failed is the number of tags which may still be in the registry.
+func (r deleteResult) failed() int {
+	n := 0
+	for _, tag := range r.Tags {
+		if tag.failure() {
+			n++
+		}
+	}
+	return n
+}
+
+// statusCounts describes the tags not deleted, like "1 not found, 2 failed".
+func statusCounts(counts map[string]int) string {
+	var parts []string
+	for _, status := range tagStatuses {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], strings.Replace(status, "_", " ", -1)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printDeleteSummary prints the tags deleted in each image once every plan
+// has run and returns their total, with an error when some tags may still
+// be in the registry.
+func printDeleteSummary(plans []imagePlan, results []deleteResult) (int, error) {
+	deleted, failed, notDeleted := 0, 0, 0
+	totals := make(map[string]int)
+	for i, plan := range plans {
+		counts := make(map[string]int)
+		for _, status := range tagStatuses {
+			counts[status] = results[i].count(status)
+			totals[status] += counts[status]
+		}
+		deleted += results[i].Deleted
+		failed += results[i].failed()
+		notDeleted += len(results[i].Tags) - results[i].Deleted
+		line := fmt.Sprintf("%s: %d of %d tags deleted", plan.Image, results[i].Deleted, len(plan.Tags))
+		if others := statusCounts(counts); others != "" {
+			line += ", " + others
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+	fmt.Fprintf(os.Stderr, "Total of %d tags deleted in %d images.\n", deleted, len(plans))
+	if notDeleted > 0 {
+		fmt.Fprintf(os.Stderr, "Total of %d tags not deleted, %s.\n", notDeleted, statusCounts(totals))
+	}
+	if failed > 0 {
+		return deleted, fmt.Errorf("%d of %d tags failed to be deleted", failed, deleted+notDeleted)
+	}
+	return deleted, nil
+}
+
+// imageResult is the JSON result of the deletion of the tags of an image.
+type imageResult struct {
+	Rule    string      `json:"rule,omitempty"`
+	Image   string      `json:"image"`
+	Deleted int         `json:"deleted"`
+	Failed  int         `json:"failed"`
+	Tags    []tagResult `json:"tags"`
+}
+
+// printResults prints the result of every tag as JSON.
+func printResults(plans []imagePlan, results []deleteResult) {
+	images := make([]imageResult, len(plans))
+	for i, plan := range plans {
+		images[i] = imageResult{
+			Rule:    plan.Rule,
+			Image:   plan.Image,
+			Deleted: results[i].Deleted,
+			Failed:  results[i].failed(),
+			Tags:    results[i].Tags,
+		}
+		if images[i].Tags == nil {
+			images[i].Tags = []tagResult{}
+		}
+	}
+	output, _ := json.Marshal(images)
+	fmt.Println(string(output))
+}
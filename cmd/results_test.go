@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestNewTagResult(t *testing.T) {
+	tdata := []struct {
+		err      error
+		expected string
+	}{
+		{nil, tagDeleted},
+		{&registry.HTTPError{StatusCode: http.StatusNotFound}, tagNotFound},
+		{&registry.HTTPError{StatusCode: http.StatusUnauthorized}, tagUnauthorized},
+		{&registry.HTTPError{StatusCode: http.StatusForbidden}, tagUnauthorized},
+		{&registry.HTTPError{StatusCode: http.StatusMethodNotAllowed}, tagUnsupported},
+		{&registry.HTTPError{StatusCode: http.StatusInternalServerError}, tagFailed},
+		{errors.New("connection refused"), tagFailed},
+	}
+	for _, test := range tdata {
+		result := newTagResult("pr-1", "sha256:1", 1024, test.err)
+		require.Equal(t, test.expected, result.Status)
+		require.Equal(t, test.err != nil && test.expected != tagNotFound, result.failure())
+		if test.err != nil {
+			require.Equal(t, int64(0), result.Bytes)
+			require.Equal(t, test.err.Error(), result.Error)
+		}
+	}
+}
+
+func TestPrintDeleteSummary(t *testing.T) {
+	plans := []imagePlan{
+		{Image: "r0mdau/nodejs", Tags: []string{"pr-1", "pr-2"}},
+		{Image: "r0mdau/php", Tags: []string{"pr-1", "pr-2"}},
+	}
+	results := make([]deleteResult, 2)
+	results[0].add(newTagResult("pr-1", "sha256:1", 0, nil))
+	results[0].add(newTagResult("pr-2", "", 0, &registry.HTTPError{StatusCode: http.StatusNotFound}))
+	results[1].add(newTagResult("pr-1", "sha256:1", 0, nil))
+	results[1].add(newTagResult("pr-2", "sha256:2", 0, nil))
+
+	deleted, err := printDeleteSummary(plans, results)
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted)
+	require.Equal(t, "1 not found", statusCounts(map[string]int{tagNotFound: results[0].count(tagNotFound)}))
+
+	results[1] = deleteResult{}
+	results[1].add(newTagResult("pr-1", "sha256:1", 0, &registry.HTTPError{StatusCode: http.StatusMethodNotAllowed}))
+	results[1].add(newTagResult("pr-2", "sha256:2", 0, errors.New("timeout")))
+	deleted, err = printDeleteSummary(plans, results)
+	require.EqualError(t, err, "2 of 4 tags failed to be deleted")
+	require.Equal(t, 1, deleted)
+	require.Equal(t, "1 unsupported, 1 failed", statusCounts(map[string]int{tagUnsupported: 1, tagFailed: 1}))
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strings"
+	"time"
+)
+
+// imagePlan holds the tags a rule, if any, deletes in one image.
+type imagePlan struct {
+	Rule  string   `json:"rule,omitempty"`
+	Image string   `json:"image"`
+	Tags  []string `json:"tags"`
+	// Protected are the tags matched but protected by a rule
+	Protected []string `json:"protected,omitempty"`
+	// Digests are the planned digests of Tags when applying a saved plan,
+	// a tag is only deleted if it still resolves to its digest
+	Digests []string `json:"digests,omitempty"`
+	// Until is the end of the maintenance window, no tag is deleted after it
+	Until time.Time `json:"-"`
+	// Operator runs the plan in the audit log, --audit-operator when empty
+	Operator string `json:"-"`
+}
+
+func runConfig(c *cli.Context) error {
+	cfg, err := config.Load(c.String("config"))
+	exit(err)
+	policies, err := configPolicies(cfg)
+	exit(err)
+	webhooks, err := configWebhooks(cfg.Notify)
+	exit(err)
+	// sizes are fetched before deletes to report the reclaimed bytes
+	stats.sizes = stats.sizes || len(webhooks) > 0
+	exit(openAuditLog(c))
+	defer auditLog.Close()
+
+	serveMetrics(c)
+	registry := newRegistry(cfg.Registry.URL, cfg.Registry.Insecure)
+	verifyRegistryVersion(registry)
+
+	plans, err := planRules(registry, cfg, policies)
+	exit(err)
+	total := 0
+	for _, plan := range plans {
+		total += len(plan.Tags)
+		fmt.Fprintf(os.Stderr, "Planned %d tags of %s by %s.\n", len(plan.Tags), plan.Image, plan.Rule)
+	}
+
+	if c.Bool("dryrun") {
+		output, _ := json.Marshal(plans)
+		fmt.Println(string(output))
+		fmt.Fprintf(os.Stderr, "Dryrun, it should delete %d tags in %d images.\n", total, len(plans))
+		return nil
+	}
+
+	if total > 0 && (c.Bool("yes") || confirm("Are you sure to delete these tags ? (maybe try --dryrun first)")) {
+		start := time.Now()
+		return finishDelete(c, "run", start, plans, deletePlans(registry, plans), webhooks)
+	}
+	return nil
+}
+
+// configPolicies compiles every rule before any request is made.
+func configPolicies(cfg config.Config) ([]policy, error) {
+	policies := make([]policy, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		var err error
+		policies[i], err = newPolicy(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", rule.Label(i), err)
+		}
+	}
+	return policies, nil
+}
+
+// planRules lists the images of each rule and selects their tags, a tag
+// selected by several rules is planned once by the first of them. The
+// catalog is only listed when a rule image is a glob.
+func planRules(registry registry.Registry, cfg config.Config, policies []policy) ([]imagePlan, error) {
+	var catalog []string
+	var plans []imagePlan
+	planned := make(map[string]bool)
+
+	for i, rule := range cfg.Rules {
+		images := []string{rule.Image}
+		if strings.ContainsAny(rule.Image, "*?") {
+			if catalog == nil {
+				repositories, err := registry.ListRepositories(cfg.Registry.Catalog)
+				if err != nil {
+					return nil, err
+				}
+				catalog = repositories.GetRepository().List
+			}
+			pattern, err := filter.NewGlobPattern(rule.Image)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", rule.Label(i), err)
+			}
+			images = nil
+			for _, image := range catalog {
+				if _, ok := pattern.Match(image); ok {
+					images = append(images, image)
+				}
+			}
+		}
+
+		for _, image := range images {
+			registryResponse, err := registry.ListImageTags(image)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Can't list tags of %s, %s\n", image, err.Error())
+				continue
+			}
+			tags := registryResponse.GetImage().Tags
+			plan, protected := planImage(registrySource{registry}, rule.Label(i), policies[i], image, tags, planned)
+			stats.countSelection(image, len(tags), len(plan.Tags))
+			for _, p := range protected {
+				fmt.Fprintf(os.Stderr, "Protected %s:%s by rule \"%s\"\n", image, p.Tag, p.Rule)
+			}
+			if len(plan.Tags) > 0 {
+				plans = append(plans, plan)
+			}
+		}
+	}
+	return plans, nil
+}
+
+// planImage selects the tags of image to delete by the rule with the given
+// label, leaving out those already planned by an earlier rule, and returns
+// the protected ones.
+func planImage(source tagSource, label string, policy policy, image string, tags []string, planned map[string]bool) (imagePlan, []filter.Protected) {
+	tagsToDelete, protected := policy.selectTags(source, image, tags)
+
+	plan := imagePlan{Rule: label, Image: image, Protected: protectedTags(protected)}
+	for _, tag := range tagsToDelete {
+		if !planned[image+":"+tag] {
+			planned[image+":"+tag] = true
+			plan.Tags = append(plan.Tags, tag)
+		}
+	}
+	return plan, protected
+}
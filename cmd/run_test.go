@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRegistryServer(t *testing.T, tags map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/_catalog" {
+			w.Write([]byte(`{"repositories":["r0mdau/nodejs","r0mdau/php","other/go"]}`))
+			return
+		}
+		for image, list := range tags {
+			if r.URL.Path == "/v2/"+image+"/tags/list" {
+				w.Write([]byte(`{"name":"` + image + `","tags":` + list + `}`))
+				return
+			}
+			if reference := strings.TrimPrefix(r.URL.Path, "/v2/"+image+"/manifests/"); reference != r.URL.Path {
+				switch r.Method {
+				case http.MethodHead:
+					w.Header().Set("Docker-Content-Digest", "sha256:"+reference)
+					return
+				case http.MethodDelete:
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunConfig(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["latest","master-1.0.0","master-1.1.0","pr-1","pr-2"]`,
+		"r0mdau/php":    `["master-2.0.0","master-2.1.0","hotfix-1"]`,
+		"other/go":      `["pr-1"]`,
+	})
+
+	cfg, err := config.Parse([]byte(`
+registry:
+  url: ` + server.URL + `
+rules:
+  - name: branches
+    image: "r0mdau/*"
+    tags:
+      - pattern: "master-*"
+        keep: 1
+  - name: everything
+    image: r0mdau/nodejs
+    tags:
+      - pattern: "*"
+    exclude: ["pr-2"]
+`))
+	require.NoError(t, err)
+
+	t.Run("Every rule is planned once per tag", func(t *testing.T) {
+		policies, err := configPolicies(cfg)
+		require.NoError(t, err)
+
+		plans, err := planRules(registry.NewRegistry(server.URL, false), cfg, policies)
+		require.NoError(t, err)
+		require.Equal(t, []imagePlan{
+			{Rule: `rule "branches"`, Image: "r0mdau/nodejs", Tags: []string{"master-1.0.0"}},
+			{Rule: `rule "branches"`, Image: "r0mdau/php", Tags: []string{"master-2.0.0"}},
+			{Rule: `rule "everything"`, Image: "r0mdau/nodejs", Tags: []string{"master-1.1.0", "pr-1"}, Protected: []string{"latest", "pr-2"}},
+		}, plans)
+	})
+
+	t.Run("Rules are compiled before any request", func(t *testing.T) {
+		invalid := cfg
+		invalid.Rules = append([]config.Rule{{Image: "a", Sort: "random"}}, cfg.Rules...)
+		_, err := configPolicies(invalid)
+		require.EqualError(t, err, "rules[0]: unknown sort strategy \"random\", use one of semver, lexical, natural, calver, timestamp, created, git-time, git-topo")
+	})
+}
@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/internal/expr"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/internal/git"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// patternList is a repeatable flag value which, unlike cli.StringSlice,
+// does not split on commas so regular expressions like \d{1,3} survive.
+type patternList []string
+
+// serializedPrefix marks the value cli copies to the other names of a flag,
+// it replaces the list instead of appending to it.
+const serializedPrefix = "patterns:::"
+
+func (p *patternList) Set(value string) error {
+	if strings.HasPrefix(value, serializedPrefix) {
+		return json.Unmarshal([]byte(strings.TrimPrefix(value, serializedPrefix)), p)
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+func (p *patternList) Serialize() string {
+	serialized, _ := json.Marshal(*p)
+	return serializedPrefix + string(serialized)
+}
+
+func (p *patternList) String() string {
+	return strings.Join(*p, " ")
+}
+
+func patterns(c *cli.Context, name string) []string {
+	if list, ok := c.Generic(name).(*patternList); ok {
+		return *list
+	}
+	return nil
+}
+
+// tagSelectors builds one selector per -t flag, paired with the -k flag at
+// the same position. A single -k applies to every -t.
+func tagSelectors(c *cli.Context) ([]filter.Selector, error) {
+	rule, err := flagRule(c)
+	if err != nil {
+		return nil, err
+	}
+	return ruleSelectors(rule)
+}
+
+// flagRule describes the selection flags as a config rule, pairing each -t
+// with the -k at the same position.
+func flagRule(c *cli.Context) (config.Rule, error) {
+	cliTags, keeps := patterns(c, "tag"), c.IntSlice("keep")
+	if len(keeps) > 1 && len(keeps) != len(cliTags) {
+		return config.Rule{}, fmt.Errorf("got %d --keep for %d --tag, give one --keep for all or one per --tag", len(keeps), len(cliTags))
+	}
+
+	rule := config.Rule{
+		Image:                       c.String("image"),
+		Regex:                       c.Bool("regex"),
+		Sort:                        c.String("sort"),
+		KeepPer:                     c.String("keep-per"),
+		Constraint:                  c.String("constraint"),
+		Where:                       c.String("where"),
+		Exclude:                     c.StringSlice("exclude"),
+		NoBuiltinProtect:            c.Bool("no-builtin-protect"),
+		OlderThan:                   c.String("older-than"),
+		DeleteSupersededPrereleases: c.Bool("delete-superseded-prereleases"),
+		GroupByPrefix:               c.Bool("group-by-prefix"),
+		DeleteStaleGroups:           c.String("delete-stale-groups"),
+		DeleteGoneBranches:          c.Bool("delete-gone-branches"),
+		GitRepo:                     c.String("git-repo"),
+		GitRefs:                     c.String("git-refs"),
+	}
+	if c.IsSet("keep-prereleases") {
+		keepPrereleases := c.Int("keep-prereleases")
+		rule.KeepPrereleases = &keepPrereleases
+	}
+	if len(keeps) == 1 {
+		rule.Keep = &keeps[0]
+	}
+	for i, cliTag := range cliTags {
+		tag := config.Tag{Pattern: cliTag}
+		if len(keeps) > 1 {
+			tag.Keep = &keeps[i]
+		}
+		rule.Tags = append(rule.Tags, tag)
+	}
+	return rule, nil
+}
+
+// ruleSelectors builds one selector per tag pattern of rule, sharing the
+// other options of the rule.
+func ruleSelectors(rule config.Rule) ([]filter.Selector, error) {
+	var err error
+	var data filter.SortData
+	if strings.HasPrefix(rule.Sort, "git-") {
+		if rule.GitRepo == "" {
+			return nil, errors.New("--sort " + rule.Sort + " needs --git-repo")
+		}
+		data.History, err = git.ReadHistory(rule.GitRepo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sorter, err := filter.NewSorter(rule.Sort, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.CheckKeepPer(rule.KeepPer); err != nil {
+		return nil, err
+	}
+	template := filter.Selector{
+		Sorter:  sorter,
+		KeepPer: rule.KeepPer,
+		Prereleases: filter.Prereleases{
+			DeleteSuperseded: rule.DeleteSupersededPrereleases,
+			Limit:            rule.KeepPrereleases != nil,
+		},
+	}
+	if rule.KeepPrereleases != nil {
+		template.Prereleases.Keep = *rule.KeepPrereleases
+	}
+
+	if rule.Constraint != "" {
+		template.Constraints, err = filter.NewConstraints(rule.Constraint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rule.DeleteStaleGroups != "" {
+		template.StaleAfter, err = filter.ParseAge(rule.DeleteStaleGroups)
+		if err != nil {
+			return nil, err
+		}
+		template.GroupByPrefix = true
+	}
+	template.GroupByPrefix = template.GroupByPrefix || rule.GroupByPrefix
+	if rule.DeleteGoneBranches {
+		refs, err := gitRefs(rule.GitRepo, rule.GitRefs)
+		if err != nil {
+			return nil, err
+		}
+		template.Git = &refs
+	}
+
+	var cliTags []string
+	for _, tag := range rule.Tags {
+		cliTags = append(cliTags, tag.Pattern)
+	}
+	regex, keeps := rule.Regex, ruleKeeps(rule)
+	conditions := rule.Where != "" || rule.OlderThan != ""
+	prereleases := rule.DeleteSupersededPrereleases || rule.KeepPrereleases != nil
+	// any tag looks like <prefix>-<semver>, ie alpine-3.18, so gone branches
+	// are only looked for among the tags of the patterns
+	if template.Git != nil && len(cliTags) == 0 {
+		return nil, errors.New("--delete-gone-branches needs --tag")
+	}
+	// constraints, conditions, groups and pre-releases alone select among
+	// all tags
+	if len(cliTags) == 0 && (template.Constraints != nil || conditions || template.GroupByPrefix || prereleases) {
+		cliTags, regex = []string{"*"}, false
+	}
+	// without keep, gone branches and pre-release options only select what
+	// they target, final releases are never affected
+	if len(keeps) == 0 && (prereleases || (template.Git != nil && template.Constraints == nil && !conditions && !template.GroupByPrefix)) {
+		keeps = []int{-1}
+	}
+	return newSelectors(cliTags, keeps, regex, template)
+}
+
+// ruleKeeps returns the keep of each tag pattern, the rule keep standing
+// for the patterns without their own, or the single keep shared by all.
+func ruleKeeps(rule config.Rule) []int {
+	perTag := false
+	for _, tag := range rule.Tags {
+		perTag = perTag || tag.Keep != nil
+	}
+	if !perTag {
+		if rule.Keep != nil {
+			return []int{*rule.Keep}
+		}
+		return nil
+	}
+
+	var keeps []int
+	for _, tag := range rule.Tags {
+		switch {
+		case tag.Keep != nil:
+			keeps = append(keeps, *tag.Keep)
+		case rule.Keep != nil:
+			keeps = append(keeps, *rule.Keep)
+		default:
+			keeps = append(keeps, 0)
+		}
+	}
+	return keeps
+}
+
+// newSelectors copies template, which holds the options shared by every
+// selector, for each tag pattern.
+func newSelectors(cliTags []string, keeps []int, regex bool, template filter.Selector) ([]filter.Selector, error) {
+	if len(keeps) > 1 && len(keeps) != len(cliTags) {
+		return nil, fmt.Errorf("got %d --keep for %d --tag, give one --keep for all or one per --tag", len(keeps), len(cliTags))
+	}
+
+	var selectors []filter.Selector
+	for i, cliTag := range cliTags {
+		pattern, err := tagPattern(cliTag, regex)
+		if err != nil {
+			return nil, err
+		}
+		selector := template
+		selector.Pattern = pattern
+		if len(keeps) == 1 {
+			selector.Keep = keeps[0]
+		} else if len(keeps) > 1 {
+			selector.Keep = keeps[i]
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+func gitRefs(repository, refsFile string) (filter.GitRefs, error) {
+	if repository != "" {
+		return git.ReadRepository(repository)
+	}
+	if refsFile != "" {
+		return git.ReadRefsFile(refsFile)
+	}
+	return filter.GitRefs{}, errors.New("--delete-gone-branches needs --git-repo or --git-refs")
+}
+
+// tagPattern parses a -t flag as a glob, or as a regular expression in regex
+// mode.
+func tagPattern(cliTag string, regex bool) (filter.Pattern, error) {
+	if regex {
+		return filter.NewRegexPattern(cliTag)
+	}
+	return filter.NewGlobPattern(cliTag)
+}
+
+// resolveSelectors applies the where expression, fetches the image creation
+// time of the matched tags when a selector sorts on it or deletes stale
+// groups, and reports the matched tags whose commit is missing from the git
+// history when sorting on it. Such tags can't be ordered and are kept.
+func resolveSelectors(source tagSource, image string, tags []string, selectors []filter.Selector, where *expr.Expression) []filter.Selector {
+	selectors = applyWhere(source, image, tags, selectors, where)
+	var created map[string]time.Time
+	now := source.Now()
+	for i, selector := range selectors {
+		selectors[i].Now = now
+		if selector.Sorter.Data.History != nil {
+			for _, tag := range tags {
+				m, ok := selector.Match(tag)
+				if !ok {
+					continue
+				}
+				if _, ok := selector.Sorter.Data.History.Resolve(m.Version); !ok {
+					fmt.Fprintf(os.Stderr, "Commit of %s:%s not found in git repository, kept\n", image, m.Tag)
+				}
+			}
+		}
+		if selector.Sorter.Name != filter.SortCreated && selector.StaleAfter <= 0 {
+			continue
+		}
+		if created == nil {
+			created = creationTimes(source, image, filter.SelectorTags(tags, selectors))
+		}
+		selectors[i].Created = created
+		if selector.Sorter.Name == filter.SortCreated {
+			selectors[i].Sorter, _ = filter.NewSorter(filter.SortCreated, filter.SortData{Created: created})
+		}
+	}
+	return selectors
+}
+
+func creationTimes(source tagSource, image string, tags []string) map[string]time.Time {
+	created := make(map[string]time.Time)
+	var mutex sync.Mutex
+	jobs := make(chan string, len(tags))
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tag := range jobs {
+				config, err := source.GetImageConfig(image, tag)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Can't get creation time of %s:%s, %s\n", image, tag, err.Error())
+					continue
+				}
+				mutex.Lock()
+				created[tag] = config.Created
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, tag := range tags {
+		jobs <- tag
+	}
+	close(jobs)
+	wg.Wait()
+	return created
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTagSelectors(t *testing.T) {
+	tags := []string{"latest", "master-1.0.1", "master-0.9.2", "master-1.0.0", "rc1.2-linux", "rc1.10-linux", "develop-1", "develop-2"}
+
+	t.Run("Select all tags without pattern", func(t *testing.T) {
+		selectors, err := newSelectors(nil, []int{2}, false, filter.Selector{})
+		require.Empty(t, selectors)
+		require.NoError(t, err)
+	})
+
+	t.Run("Single keep applies to every tag pattern", func(t *testing.T) {
+		selectors, err := newSelectors([]string{"master-*", "develop-*"}, []int{1}, false, filter.Selector{})
+		require.NoError(t, err)
+		actual, _ := filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"master-0.9.2", "master-1.0.0", "develop-1"}, actual)
+	})
+
+	t.Run("Keeps are paired with tag patterns by position", func(t *testing.T) {
+		selectors, err := newSelectors([]string{"master-*", "develop-*"}, []int{2, 0}, false, filter.Selector{})
+		require.NoError(t, err)
+		actual, _ := filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"master-0.9.2", "develop-1", "develop-2"}, actual)
+	})
+
+	t.Run("Mismatched keep count is rejected", func(t *testing.T) {
+		_, err := newSelectors([]string{"master-*", "develop-*", "pr-*"}, []int{2, 0}, false, filter.Selector{})
+		require.Error(t, err)
+	})
+
+	t.Run("Select with regex mode sorted on version group", func(t *testing.T) {
+		selectors, err := newSelectors([]string{`^rc(?P<version>[\d.]{1,5})-linux$`}, []int{1}, true, filter.Selector{})
+		require.NoError(t, err)
+		actual, _ := filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"rc1.2-linux"}, actual)
+	})
+
+	t.Run("Template options are applied to every selector", func(t *testing.T) {
+		sorter, _ := filter.NewSorter("natural", filter.SortData{})
+		selectors, err := newSelectors([]string{"develop-*", "master-*"}, []int{1}, false, filter.Selector{Sorter: sorter, KeepPer: "major"})
+		require.NoError(t, err)
+		require.Equal(t, "natural", selectors[1].Sorter.Name)
+		require.Equal(t, "major", selectors[1].KeepPer)
+	})
+
+	t.Run("Invalid regex is rejected", func(t *testing.T) {
+		_, err := newSelectors([]string{"rc("}, nil, true, filter.Selector{})
+		require.Error(t, err)
+	})
+
+	t.Run("Regex tag flags are not split on commas", func(t *testing.T) {
+		var selectors []filter.Selector
+		app := newTestApp()
+		app.Commands[2].Action = func(c *cli.Context) error {
+			var err error
+			selectors, err = tagSelectors(c)
+			return err
+		}
+
+		err := app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "--regex", "-t", `^v\d{1,3}$`, "-t", "^rc", "-k", "1", "-k", "2"})
+
+		require.NoError(t, err)
+		require.Len(t, selectors, 2)
+		require.Equal(t, 2, selectors[1].Keep)
+		_, ok := selectors[0].Pattern.Match("v12")
+		require.True(t, ok)
+	})
+
+	t.Run("Unknown sort strategy and keep per are rejected", func(t *testing.T) {
+		app := newTestApp()
+		app.Commands[2].Action = func(c *cli.Context) error {
+			_, err := tagSelectors(c)
+			return err
+		}
+
+		require.Error(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "--sort", "random"}))
+		require.Error(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "--keep-per", "patch"}))
+		require.Error(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "--sort", "git-topo"}))
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "--keep-per", "minor"}))
+	})
+
+	t.Run("Constraint applies to every tag pattern or alone to all tags", func(t *testing.T) {
+		var selectors []filter.Selector
+		run := func(args ...string) error {
+			app := newTestApp()
+			app.Commands[2].Action = func(c *cli.Context) error {
+				var err error
+				selectors, err = tagSelectors(c)
+				return err
+			}
+			return app.Run(append([]string{"", "delete", "-u", "http://localhost", "-i", "image"}, args...))
+		}
+
+		require.NoError(t, run("-t", "master-*", "--constraint", ">=1.0, <1.5"))
+		actual, _ := filter.Select([]string{"master-0.9.2", "master-1.0.0", "master-1.5.0", "1.2.0"}, selectors, nil)
+		require.Equal(t, []string{"master-1.0.0"}, actual)
+
+		require.NoError(t, run("--regex", "--constraint", "<2.0.0", "-k", "1"))
+		actual, _ = filter.Select([]string{"1.0.0", "1.1.0", "2.0.0", "master-1.0.0"}, selectors, nil)
+		require.Equal(t, []string{"1.0.0"}, actual)
+
+		require.Error(t, run("--constraint", "<<2"))
+	})
+
+	t.Run("Stale groups imply grouping on all tags", func(t *testing.T) {
+		var selectors []filter.Selector
+		app := newTestApp()
+		app.Commands[2].Action = func(c *cli.Context) error {
+			var err error
+			selectors, err = tagSelectors(c)
+			return err
+		}
+
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-k", "1", "--delete-stale-groups", "30d"}))
+		require.Len(t, selectors, 1)
+		require.True(t, selectors[0].GroupByPrefix)
+		require.Equal(t, 30*24*time.Hour, selectors[0].StaleAfter)
+		actual, _ := filter.Select([]string{"a-1.0.0", "a-1.1.0", "b-1.0.0", "latest"}, selectors, nil)
+		require.Equal(t, []string{"a-1.0.0"}, actual)
+	})
+
+	t.Run("Gone branches need a git source", func(t *testing.T) {
+		refsFile := filepath.Join(t.TempDir(), "refs")
+		require.NoError(t, ioutil.WriteFile(refsFile, []byte("3f2a9c1 commit\trefs/heads/main\n"), 0644))
+
+		var selectors []filter.Selector
+		run := func(args ...string) error {
+			app := newTestApp()
+			app.Commands[2].Action = func(c *cli.Context) error {
+				var err error
+				selectors, err = tagSelectors(c)
+				return err
+			}
+			return app.Run(append([]string{"", "delete", "-u", "http://localhost", "-i", "image"}, args...))
+		}
+
+		require.Error(t, run("--delete-gone-branches", "-t", "*"))
+		require.EqualError(t, run("--delete-gone-branches", "--git-refs", refsFile), "--delete-gone-branches needs --tag")
+		require.NoError(t, run("--delete-gone-branches", "--git-refs", refsFile, "-t", "*-*"))
+		actual, _ := filter.Select([]string{"main-1.0.0", "old-1.0.0", "latest"}, selectors, nil)
+		require.Equal(t, []string{"old-1.0.0"}, actual)
+
+		require.NoError(t, run("--delete-gone-branches", "--git-refs", refsFile, "-t", "main-*", "-k", "0"))
+		actual, _ = filter.Select([]string{"main-1.0.0", "old-1.0.0", "latest"}, selectors, nil)
+		require.Equal(t, []string{"main-1.0.0"}, actual)
+	})
+
+	t.Run("Pre-release options are applied to every tag pattern", func(t *testing.T) {
+		var selectors []filter.Selector
+		app := newTestApp()
+		app.Commands[2].Action = func(c *cli.Context) error {
+			var err error
+			selectors, err = tagSelectors(c)
+			return err
+		}
+
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "*", "-k", "10", "--delete-superseded-prereleases", "--keep-prereleases", "1"}))
+		actual, _ := filter.Select([]string{"1.0.0-rc.1", "1.0.0", "1.1.0-rc.1", "1.1.0-rc.2"}, selectors, nil)
+		require.Equal(t, []string{"1.0.0-rc.1", "1.1.0-rc.1"}, actual)
+
+		tags := []string{"1.1.0", "1.2.0-rc.1", "1.2.0", "1.3.0-rc.1", "master-1.0.0"}
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "--delete-superseded-prereleases"}))
+		actual, _ = filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"1.2.0-rc.1"}, actual)
+
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "-t", "1.*", "--keep-prereleases", "0"}))
+		actual, _ = filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"1.2.0-rc.1", "1.3.0-rc.1"}, actual)
+	})
+}
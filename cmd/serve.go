@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxPlans is the number of plans the API server keeps, the oldest are
+// dropped first.
+const maxPlans = 100
+
+// apiPlan is the dryrun of a rule, kept by the API server to be executed.
+type apiPlan struct {
+	ID      string      `json:"id"`
+	Created time.Time   `json:"created"`
+	Images  []imagePlan `json:"images"`
+	Tags    int         `json:"tags"`
+	// Run is the ID of the run that executed the plan
+	Run int `json:"run,omitempty"`
+}
+
+// apiToken is a bearer token of the API and the name of its holder, the
+// operator of the runs it starts in the audit log.
+type apiToken struct {
+	Name   string
+	Secret string
+}
+
+// apiServer is the REST API of the serve command, every request needs one
+// of the bearer tokens.
+type apiServer struct {
+	registry registry.Registry
+	catalog  int
+	tokens   []apiToken
+	history  *runHistory
+
+	mutex   sync.Mutex
+	plans   []*apiPlan
+	running bool
+	wg      sync.WaitGroup
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	holder, ok := s.authorized(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	route := r.Method + " " + path
+	switch {
+	case route == "GET /api/repositories":
+		s.listRepositories(w)
+	case route == "GET /api/tags":
+		s.listTags(w, r.URL.Query().Get("image"))
+	case route == "POST /api/plans":
+		s.createPlan(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/plans/"):
+		s.getPlan(w, strings.TrimPrefix(path, "/api/plans/"))
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/api/plans/") && strings.HasSuffix(path, "/run"):
+		s.runPlan(w, strings.TrimSuffix(strings.TrimPrefix(path, "/api/plans/"), "/run"), holder)
+	case route == "GET /api/runs":
+		writeJSON(w, http.StatusOK, s.history.list())
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/runs/"):
+		s.getRun(w, strings.TrimPrefix(path, "/api/runs/"))
+	default:
+		writeError(w, http.StatusNotFound, "no route for %s %s", r.Method, r.URL.Path)
+	}
+}
+
+// authorized returns the name of the holder of the bearer token of r.
+func (s *apiServer) authorized(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := []byte(strings.TrimPrefix(header, "Bearer "))
+	holder, authorized := "", false
+	for _, known := range s.tokens {
+		if subtle.ConstantTimeCompare(token, []byte(known.Secret)) == 1 {
+			holder, authorized = known.Name, true
+		}
+	}
+	return holder, authorized
+}
+
+func (s *apiServer) listRepositories(w http.ResponseWriter) {
+	repositories, err := s.registry.ListRepositories(s.catalog)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, repositories.GetRepository())
+}
+
+func (s *apiServer) listTags(w http.ResponseWriter, image string) {
+	if image == "" {
+		writeError(w, http.StatusBadRequest, "image query parameter is required")
+		return
+	}
+	tags, err := s.registry.ListImageTags(image)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tags.GetImage())
+}
+
+// createPlan plans the rule of the request body, JSON or YAML with the keys
+// of policy files but the server paths of git, and keeps the plan with the
+// digests of its tags to be run later, skipping those pushed again since.
+func (s *apiServer) createPlan(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	rule, err := config.ParseRule(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if rule.GitRepo != "" || rule.GitRefs != "" {
+		writeError(w, http.StatusBadRequest, "git-repo and git-refs are paths on the server, not allowed in API rules")
+		return
+	}
+	compiled, err := newPolicy(rule)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	cfg := config.Config{Registry: config.Registry{Catalog: s.catalog}, Rules: []config.Rule{rule}}
+	plans, err := planRules(s.registry, cfg, []policy{compiled})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	images := make([]plannedImage, len(plans))
+	for i, image := range plans {
+		images[i] = resolveDigests(s.registry, image)
+	}
+	plans = planFile{Images: images}.plans()
+	plan := &apiPlan{ID: newPlanID(), Created: time.Now(), Images: plans}
+	for _, image := range plans {
+		plan.Tags += len(image.Tags)
+	}
+
+	s.mutex.Lock()
+	s.plans = append(s.plans, plan)
+	if len(s.plans) > maxPlans {
+		s.plans = s.plans[len(s.plans)-maxPlans:]
+	}
+	s.mutex.Unlock()
+	writeJSON(w, http.StatusCreated, plan)
+}
+
+func (s *apiServer) findPlan(id string) *apiPlan {
+	for _, plan := range s.plans {
+		if plan.ID == id {
+			return plan
+		}
+	}
+	return nil
+}
+
+func (s *apiServer) getPlan(w http.ResponseWriter, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	plan := s.findPlan(id)
+	if plan == nil {
+		writeError(w, http.StatusNotFound, "no plan %s", id)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// runPlan deletes the tags of a plan in the background for the token
+// holder, one run at a time and once per plan.
+func (s *apiServer) runPlan(w http.ResponseWriter, id, holder string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	plan := s.findPlan(id)
+	switch {
+	case plan == nil:
+		writeError(w, http.StatusNotFound, "no plan %s", id)
+		return
+	case plan.Run != 0:
+		writeError(w, http.StatusConflict, "plan %s already ran in run %d", id, plan.Run)
+		return
+	case s.running:
+		writeError(w, http.StatusConflict, "a run is in progress")
+		return
+	}
+
+	run := s.history.next(time.Now())
+	run.Plan, run.Images, run.Planned = plan.ID, len(plan.Images), plan.Tags
+	run.Operator = holder
+	plan.Run = run.ID
+	for i := range plan.Images {
+		plan.Images[i].Operator = holder
+	}
+	s.running = true
+	s.history.record(run)
+	s.wg.Add(1)
+	go func(run runRecord) {
+		defer s.wg.Done()
+		var err error
+		run.Deleted, err = printDeleteSummary(plan.Images, deletePlans(s.registry, plan.Images))
+		if err == nil {
+			stats.succeeded()
+		}
+		s.history.finish(run, err)
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.running = false
+	}(run)
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+func (s *apiServer) getRun(w http.ResponseWriter, id string) {
+	runID, err := strconv.Atoi(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid run id %s", id)
+		return
+	}
+	run, ok := s.history.get(runID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no run %d", runID)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func newPlanID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// loadTokens reads one bearer token per line, after the name of its holder
+// if any, skipping blank lines and comments. Tokens without name are named
+// by their line.
+func loadTokens(path string) ([]apiToken, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []apiToken
+	for i, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], "#"):
+			continue
+		case len(fields) == 1:
+			tokens = append(tokens, apiToken{fmt.Sprintf("token of line %d", i+1), fields[0]})
+		case len(fields) == 2:
+			tokens = append(tokens, apiToken{fields[0], fields[1]})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a token, or a name and a token", path, i+1)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no token in %s", path)
+	}
+	return tokens, nil
+}
+
+// serveAPI runs the REST API until interrupted, then waits for the current
+// run.
+func serveAPI(c *cli.Context) error {
+	tokens, err := loadTokens(c.String("token-file"))
+	exit(err)
+	exit(openAuditLog(c))
+	defer auditLog.Close()
+
+	serveMetrics(c)
+	registry := newRegistry(c.String("url"), c.Bool("insecure"))
+	verifyRegistryVersion(registry)
+
+	api := &apiServer{
+		registry: registry,
+		catalog:  c.Int("n"),
+		tokens:   tokens,
+		history:  &runHistory{size: 100},
+	}
+	server := &http.Server{Addr: c.String("listen"), Handler: api}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Fprintf(os.Stderr, "Stopping, waiting for the current run.\n")
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving the API on %s.\n", server.Addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	api.wg.Wait()
+	return nil
+}
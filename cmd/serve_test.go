@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIServer(t *testing.T) {
+	server := newTestRegistryServer(t, map[string]string{
+		"r0mdau/nodejs": `["latest","pr-1","pr-2","pr-3"]`,
+		"r0mdau/php":    `["pr-1"]`,
+	})
+	api := &apiServer{
+		registry: registry.NewRegistry(server.URL, false),
+		catalog:  100,
+		tokens:   []apiToken{{"ci", "secret"}, {"ops", "other"}},
+		history:  &runHistory{size: 10},
+	}
+	request := func(method, path, token, body string, value interface{}) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, r)
+		if value != nil {
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), value))
+		}
+		return recorder.Code
+	}
+
+	t.Run("Bearer token is required", func(t *testing.T) {
+		var body map[string]string
+		require.Equal(t, http.StatusUnauthorized, request("GET", "/api/repositories", "", "", &body))
+		require.Equal(t, "missing or invalid bearer token", body["error"])
+		require.Equal(t, http.StatusUnauthorized, request("GET", "/api/repositories", "wrong", "", nil))
+	})
+
+	t.Run("Repositories and tags are listed", func(t *testing.T) {
+		var repositories registry.Repository
+		require.Equal(t, http.StatusOK, request("GET", "/api/repositories", "other", "", &repositories))
+		require.Equal(t, []string{"r0mdau/nodejs", "r0mdau/php", "other/go"}, repositories.List)
+
+		var image registry.Image
+		require.Equal(t, http.StatusOK, request("GET", "/api/tags?image=r0mdau/php", "secret", "", &image))
+		require.Equal(t, registry.Image{Name: "r0mdau/php", Tags: []string{"pr-1"}}, image)
+		require.Equal(t, http.StatusBadRequest, request("GET", "/api/tags", "secret", "", nil))
+		require.Equal(t, http.StatusNotFound, request("GET", "/api/unknown", "secret", "", nil))
+	})
+
+	t.Run("Plans are dryruns executed once", func(t *testing.T) {
+		var body map[string]string
+		require.Equal(t, http.StatusBadRequest, request("POST", "/api/plans", "secret", `{"image": "r0mdau/*", "kep": 1}`, &body))
+		require.Equal(t, "line 1: unknown key \"kep\" in rule, did you mean \"keep\"?", body["error"])
+		require.Equal(t, http.StatusBadRequest, request("POST", "/api/plans", "secret", `{"image": "r0mdau/*", "sort": "git-topo", "git-repo": "/etc"}`, &body))
+		require.Equal(t, "git-repo and git-refs are paths on the server, not allowed in API rules", body["error"])
+
+		var plan apiPlan
+		require.Equal(t, http.StatusCreated, request("POST", "/api/plans", "secret", `{"image": "r0mdau/*", "tags": [{"pattern": "pr-*", "keep": 1}]}`, &plan))
+		require.Len(t, plan.ID, 16)
+		require.Equal(t, 2, plan.Tags)
+		require.Equal(t, []imagePlan{{
+			Rule:    "rules[0]",
+			Image:   "r0mdau/nodejs",
+			Tags:    []string{"pr-1", "pr-2"},
+			Digests: []string{"sha256:pr-1", "sha256:pr-2"},
+		}}, plan.Images)
+
+		var run runRecord
+		require.Equal(t, http.StatusAccepted, request("POST", "/api/plans/"+plan.ID+"/run", "secret", "", &run))
+		require.Equal(t, plan.ID, run.Plan)
+		require.Equal(t, 2, run.Planned)
+		require.Equal(t, "ci", run.Operator)
+		api.wg.Wait()
+		require.Equal(t, "ci", api.plans[0].Images[0].Operator)
+
+		require.Equal(t, http.StatusOK, request("GET", "/api/runs/1", "secret", "", &run))
+		require.Equal(t, runSucceeded, run.Status)
+		require.Equal(t, 2, run.Deleted)
+		require.Equal(t, http.StatusConflict, request("POST", "/api/plans/"+plan.ID+"/run", "secret", "", &body))
+		require.Equal(t, "plan "+plan.ID+" already ran in run 1", body["error"])
+		require.Equal(t, http.StatusOK, request("GET", "/api/plans/"+plan.ID, "secret", "", &plan))
+		require.Equal(t, 1, plan.Run)
+
+		var runs []runRecord
+		require.Equal(t, http.StatusOK, request("GET", "/api/runs", "secret", "", &runs))
+		require.Len(t, runs, 1)
+		require.Equal(t, http.StatusNotFound, request("GET", "/api/runs/2", "secret", "", nil))
+		require.Equal(t, http.StatusNotFound, request("POST", "/api/plans/unknown/run", "secret", "", nil))
+	})
+}
+
+func TestLoadTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, ioutil.WriteFile(path, []byte("# ci\nci secret\n\n  other  \n"), 0600))
+	tokens, err := loadTokens(path)
+	require.NoError(t, err)
+	require.Equal(t, []apiToken{{"ci", "secret"}, {"token of line 4", "other"}}, tokens)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("ci secret other\n"), 0600))
+	_, err = loadTokens(path)
+	require.EqualError(t, err, path+":1: expected a token, or a name and a token")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("# none\n"), 0600))
+	_, err = loadTokens(path)
+	require.EqualError(t, err, "no token in "+path)
+}
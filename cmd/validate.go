@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/config"
+	"github.com/urfave/cli/v2"
+	"os"
+)
+
+func validateConfig(c *cli.Context) error {
+	path := c.String("config")
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	errors, warnings := 0, 0
+	for _, problem := range config.Lint(cfg) {
+		fmt.Printf("%s: %s\n", path, problem)
+		if problem.Severity == config.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Total of %d errors and %d warnings in %d rules.\n", errors, warnings, len(cfg.Rules))
+	if errors > 0 || (c.Bool("strict") && warnings > 0) {
+		return fmt.Errorf("%s is not valid", path)
+	}
+	return nil
+}
+
+func printConfigSchema(c *cli.Context) error {
+	output, _ := json.MarshalIndent(config.Schema(), "", "  ")
+	fmt.Println(string(output))
+	return nil
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		require.NoError(t, ioutil.WriteFile(path, []byte("registry:\n  url: http://localhost\nrules:\n"+content), 0644))
+		return path
+	}
+	validate := func(args ...string) error {
+		return newTestApp().Run(append([]string{"", "config", "validate"}, args...))
+	}
+
+	valid := write("  - image: r0mdau/nodejs\n    tags:\n      - pattern: \"pr-*\"\n        keep: 2\n")
+	require.NoError(t, validate("--config", valid, "--strict"))
+
+	dangerous := write("  - image: r0mdau/nodejs\n    tags:\n      - pattern: \"*\"\n")
+	require.NoError(t, validate("--config", dangerous))
+	require.EqualError(t, validate("--config", dangerous, "--strict"), dangerous+" is not valid")
+
+	invalid := write("  - image: r0mdau/nodejs\n    keep-per: patch\n    tags:\n      - pattern: \"pr-*\"\n")
+	require.EqualError(t, validate("--config", invalid), invalid+" is not valid")
+
+	unknownKey := write("  - images: r0mdau/nodejs\n")
+	require.EqualError(t, validate("--config", unknownKey), unknownKey+": line 4: unknown key \"images\" in config.rules[0], did you mean \"image\"?")
+
+	require.NoError(t, newTestApp().Run([]string{"", "config", "schema"}))
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/expr"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"os"
+	"sync"
+	"time"
+)
+
+// infoVariables are the expression variables read from the registry.
+var infoVariables = []string{"digest", "size", "created", "labels", "platform"}
+
+// whereExpression parses the where condition and adds the older-than age
+// limit to it, nil when neither is set.
+func whereExpression(where, olderThan string) (*expr.Expression, error) {
+	if where != "" {
+		if _, err := expr.Parse(where); err != nil {
+			return nil, fmt.Errorf("invalid where expression at %v", err)
+		}
+	}
+	if olderThan != "" {
+		age, err := filter.ParseAge(olderThan)
+		if err != nil {
+			return nil, err
+		}
+		limit := fmt.Sprintf("age(created) > %ds", int64(age.Seconds()))
+		if where == "" {
+			where = limit
+		} else {
+			where = limit + " && (" + where + ")"
+		}
+	}
+	if where == "" {
+		return nil, nil
+	}
+	return expr.Parse(where)
+}
+
+// applyWhere evaluates where once for each matched tag of each selector,
+// manifests and configs are only fetched when the expression reads them.
+// Tags it can't be evaluated on are reported and not selected.
+func applyWhere(source tagSource, image string, tags []string, selectors []filter.Selector, where *expr.Expression) []filter.Selector {
+	if where == nil {
+		return selectors
+	}
+	var infos map[string]registry.ImageInfo
+	for _, name := range infoVariables {
+		if where.Uses(name) {
+			infos = imageInfos(source, image, filter.SelectorTags(tags, selectors))
+			break
+		}
+	}
+
+	now := source.Now()
+	for i, selector := range selectors {
+		satisfied := make(map[string]bool)
+		for _, m := range filter.MatchTags(tags, selector.Pattern) {
+			var info *registry.ImageInfo
+			if found, ok := infos[m.Tag]; ok {
+				info = &found
+			}
+			ok, err := where.Eval(tagVars(m, info, now))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Can't evaluate --where on %s:%s, %s\n", image, m.Tag, err.Error())
+			}
+			satisfied[m.Tag] = ok
+		}
+		selectors[i].Where = func(m filter.Match) bool {
+			return satisfied[m.Tag]
+		}
+	}
+	return selectors
+}
+
+// tagVars returns the expression variables of a match at time now, those
+// read from the registry are missing without info.
+func tagVars(m filter.Match, info *registry.ImageInfo, now time.Time) expr.Vars {
+	prefix, _, _ := filter.SplitPrefix(m.Tag)
+	vars := expr.Vars{
+		"tag":     m.Tag,
+		"version": m.Version,
+		"prefix":  prefix,
+		"now":     now,
+	}
+	if info != nil {
+		vars["digest"] = info.Digest
+		vars["size"] = info.Size
+		vars["created"] = info.Created
+		vars["labels"] = info.Labels
+		vars["platform"] = info.Platform
+	}
+	return vars
+}
+
+func imageInfos(source tagSource, image string, tags []string) map[string]registry.ImageInfo {
+	infos := make(map[string]registry.ImageInfo)
+	var mutex sync.Mutex
+	jobs := make(chan string, len(tags))
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tag := range jobs {
+				info, err := source.GetImageInfo(image, tag)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Can't get image info of %s:%s, %s\n", image, tag, err.Error())
+					continue
+				}
+				mutex.Lock()
+				infos[tag] = info
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, tag := range tags {
+		jobs <- tag
+	}
+	close(jobs)
+	wg.Wait()
+	return infos
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/expr"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/pkg/registry"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	t.Run("Invalid expression is rejected with its position", func(t *testing.T) {
+		_, err := whereExpression("prefix == 'master' && tags == ''", "30d")
+		require.EqualError(t, err, "invalid where expression at 1:23: unknown variable \"tags\"")
+	})
+
+	t.Run("Older than is added to the where expression", func(t *testing.T) {
+		where, err := whereExpression("prefix == 'master'", "30d")
+		require.NoError(t, err)
+		require.Equal(t, "age(created) > 2592000s && (prefix == 'master')", where.String())
+
+		where, err = whereExpression("", "1h30m")
+		require.NoError(t, err)
+		require.Equal(t, "age(created) > 5400s", where.String())
+
+		where, err = whereExpression("", "")
+		require.NoError(t, err)
+		require.Nil(t, where)
+	})
+
+	t.Run("Where alone selects among all tags", func(t *testing.T) {
+		var selectors []filter.Selector
+		var where *expr.Expression
+		app := newTestApp()
+		app.Commands[2].Action = func(c *cli.Context) error {
+			var err error
+			selectors, err = tagSelectors(c)
+			if err != nil {
+				return err
+			}
+			where, err = whereExpression(c.String("where"), "")
+			return err
+		}
+
+		require.NoError(t, app.Run([]string{"", "delete", "-u", "http://localhost", "-i", "image", "--where", "prefix == 'pr' || !semver(version)"}))
+		selectors = applyWhere(registrySource{}, "image", []string{"pr-1.0.0", "master-1.0.0", "1.0.0", "nightly"}, selectors, where)
+		actual, _ := filter.Select([]string{"pr-1.0.0", "master-1.0.0", "1.0.0", "nightly"}, selectors, nil)
+		require.Equal(t, []string{"pr-1.0.0", "master-1.0.0", "nightly"}, actual)
+	})
+
+	t.Run("Image info is fetched when the expression reads it", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/image/manifests/small", "/v2/image/manifests/big":
+				size := "10"
+				if r.URL.Path == "/v2/image/manifests/big" {
+					size = "2000000000"
+				}
+				w.Write([]byte(`{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"digest":"sha256:config","size":` + size + `}}`))
+			case "/v2/image/blobs/sha256:config":
+				w.Write([]byte(`{"created":"2024-01-31T15:30:00Z","config":{"Labels":{"keep":"true"}}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		where, err := expr.Parse("size > 1GB || labels['keep'] != 'true'")
+		require.NoError(t, err)
+		tags := []string{"small", "big", "missing"}
+		selectors := applyWhere(registrySource{registry.NewRegistry(server.URL, false)}, "image", tags, []filter.Selector{newTestSelector(t, "*")}, where)
+		actual, _ := filter.Select(tags, selectors, nil)
+		require.Equal(t, []string{"big"}, actual)
+	})
+}
+
+func newTestSelector(t *testing.T, glob string) filter.Selector {
+	pattern, err := filter.NewGlobPattern(glob)
+	require.NoError(t, err)
+	return filter.Selector{Pattern: pattern}
+}
@@ -7,4 +7,5 @@ require (
 	github.com/hashicorp/go-version v1.3.0
 	github.com/stretchr/testify v1.7.0
 	github.com/urfave/cli/v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
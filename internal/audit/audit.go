@@ -0,0 +1,150 @@
+// Package audit appends a JSON line per deletion to a log file or stdout.
+// A deletion is recorded pending before the request, then again with its
+// result under the same ID, the log is never rewritten.
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ResultPending = "pending"
+	ResultDeleted = "deleted"
+	ResultFailed  = "failed"
+)
+
+// Record is one line of the audit log.
+type Record struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Registry string    `json:"registry"`
+	Image    string    `json:"image"`
+	Tag      string    `json:"tag"`
+	Digest   string    `json:"digest"`
+	Rule     string    `json:"rule"`
+	Operator string    `json:"operator"`
+	Host     string    `json:"host"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Log writes records, safe for concurrent use. A nil Log writes nothing.
+type Log struct {
+	// Operator and Host fill the records without them
+	Operator string
+	Host     string
+
+	mutex   sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	writer  io.Writer
+	size    int64
+}
+
+// Open appends to the file at path, or stdout when path is "-". The file is
+// rotated to path.1, path.2... once maxSize bytes are reached, keeping
+// backups files, and never when maxSize is 0.
+func Open(path string, maxSize int64, backups int) (*Log, error) {
+	host, _ := os.Hostname()
+	l := &Log{Host: host, path: path, maxSize: maxSize, backups: backups}
+	if path == "-" {
+		l.writer = os.Stdout
+		return l, nil
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.writer, l.size = file, file, info.Size()
+	return nil
+}
+
+// rotate shifts the backups, dropping the oldest, and starts a new file.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.backups))
+	for i := l.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.backups > 0 {
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Write appends record as a JSON line.
+func (l *Log) Write(record Record) error {
+	if l == nil {
+		return nil
+	}
+	if record.Operator == "" {
+		record.Operator = l.Operator
+	}
+	if record.Host == "" {
+		record.Host = l.Host
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file != nil && l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.writer.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if l.file != nil {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the log file, if any.
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// NewID returns a random ID shared by the records of a deletion.
+func NewID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readRecords(t *testing.T, path string) []Record {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var record Record
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path, 0, 0)
+	require.NoError(t, err)
+	log.Operator, log.Host = "ci", "runner-1"
+
+	record := Record{ID: NewID(), Time: time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC), Registry: "https://registry.example.com", Image: "r0mdau/nodejs", Tag: "pr-1", Digest: "sha256:1", Rule: "rules[0]", Result: ResultPending}
+	require.NoError(t, log.Write(record))
+	record.Result, record.Error = ResultFailed, "401 Unauthorized"
+	require.NoError(t, log.Write(record))
+	require.NoError(t, log.Close())
+
+	// appended when reopened
+	log, err = Open(path, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, log.Write(Record{ID: "other", Result: ResultDeleted}))
+	require.NoError(t, log.Close())
+
+	records := readRecords(t, path)
+	require.Len(t, records, 3)
+	require.Equal(t, "ci", records[0].Operator)
+	require.Equal(t, "runner-1", records[0].Host)
+	require.Equal(t, ResultPending, records[0].Result)
+	require.Equal(t, records[0].ID, records[1].ID)
+	require.Equal(t, "401 Unauthorized", records[1].Error)
+	require.Equal(t, "other", records[2].ID)
+
+	var disabled *Log
+	require.NoError(t, disabled.Write(record))
+	require.NoError(t, disabled.Close())
+}
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path, 100, 2)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, log.Write(Record{ID: string(rune('a' + i)), Result: ResultDeleted}))
+	}
+	require.NoError(t, log.Close())
+
+	// records are longer than 100 bytes, one per file
+	require.Equal(t, "e", readRecords(t, path)[0].ID)
+	require.Equal(t, "d", readRecords(t, path+".1")[0].ID)
+	require.Equal(t, "c", readRecords(t, path+".2")[0].ID)
+	_, err = ioutil.ReadFile(path + ".3")
+	require.Error(t, err)
+}
@@ -0,0 +1,253 @@
+// Package config reads the YAML policy files of the run command, one
+// registry and the rules to apply to its images:
+//
+//	registry:
+//	  url: https://registry.docker.example.com
+//	rules:
+//	  - name: branches
+//	    image: "r0mdau/*"
+//	    tags:
+//	      - pattern: "master-*"
+//	        keep: 10
+//	      - pattern: "pr-*"
+//	    exclude: ["hotfix-*"]
+//	    older-than: 30d
+//
+// The optional schedule section is read by the daemon command, the notify
+// section by the run and daemon commands. Rule keys are named like the
+// flags of the delete command.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"strings"
+)
+
+type Config struct {
+	Registry Registry  `yaml:"registry"`
+	Schedule Schedule  `yaml:"schedule"`
+	Notify   []Webhook `yaml:"notify"`
+	Rules    []Rule    `yaml:"rules"`
+}
+
+type Registry struct {
+	URL      string `yaml:"url"`
+	Insecure bool   `yaml:"insecure"`
+	// Catalog is the number of repositories listed to match image globs
+	Catalog int `yaml:"catalog"`
+}
+
+// Schedule tells the daemon command when to run the rules, on a cron
+// expression or at a fixed interval.
+type Schedule struct {
+	Cron  string `yaml:"cron"`
+	Every string `yaml:"every"`
+	// Jitter is the maximum random delay added to each run
+	Jitter string `yaml:"jitter"`
+	// Window is the daily time range deletes are allowed in
+	Window string `yaml:"window"`
+	// History is the number of runs kept in memory
+	History int `yaml:"history"`
+	// Line is the line of the schedule in the config file
+	Line int `yaml:"-"`
+}
+
+// Webhook is where the run and daemon commands post the summary of each
+// run, Template renders the body or the Slack text.
+type Webhook struct {
+	URL      string `yaml:"url"`
+	Format   string `yaml:"format"`
+	Template string `yaml:"template"`
+	Retries  *int   `yaml:"retries"`
+	Timeout  string `yaml:"timeout"`
+	// Line is the line of the notify section in the config file
+	Line int `yaml:"-"`
+}
+
+// Rule selects the tags to delete in the images matching Image, like one
+// run of the delete command. Keep applies to the tags without their own.
+type Rule struct {
+	Name                        string   `yaml:"name"`
+	Image                       string   `yaml:"image"`
+	Tags                        []Tag    `yaml:"tags"`
+	Regex                       bool     `yaml:"regex"`
+	Keep                        *int     `yaml:"keep"`
+	Sort                        string   `yaml:"sort"`
+	KeepPer                     string   `yaml:"keep-per"`
+	Constraint                  string   `yaml:"constraint"`
+	Where                       string   `yaml:"where"`
+	Exclude                     []string `yaml:"exclude"`
+	NoBuiltinProtect            bool     `yaml:"no-builtin-protect"`
+	OlderThan                   string   `yaml:"older-than"`
+	DeleteSupersededPrereleases bool     `yaml:"delete-superseded-prereleases"`
+	KeepPrereleases             *int     `yaml:"keep-prereleases"`
+	GroupByPrefix               bool     `yaml:"group-by-prefix"`
+	DeleteStaleGroups           string   `yaml:"delete-stale-groups"`
+	DeleteGoneBranches          bool     `yaml:"delete-gone-branches"`
+	GitRepo                     string   `yaml:"git-repo"`
+	GitRefs                     string   `yaml:"git-refs"`
+	// Line is the line of the rule in the config file
+	Line int `yaml:"-"`
+}
+
+type Tag struct {
+	Pattern string `yaml:"pattern"`
+	Keep    *int   `yaml:"keep"`
+}
+
+const (
+	defaultCatalog = 5000
+	defaultHistory = 100
+)
+
+// Load reads and parses the config file at path.
+func Load(path string) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	config, err := Parse(content)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Parse decodes content strictly, unknown keys are errors suggesting the
+// closest known key, and checks the required settings.
+func Parse(content []byte) (Config, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return Config{}, err
+	}
+	if len(document.Content) == 0 {
+		return Config{}, errors.New("empty config")
+	}
+	if problems := unknownKeys(document.Content[0], configType, "config"); len(problems) > 0 {
+		return Config{}, errors.New(strings.Join(problems, "\n"))
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, err
+	}
+	for i, line := range ruleLines(document.Content[0]) {
+		if i < len(config.Rules) {
+			config.Rules[i].Line = line
+		}
+	}
+	config.Schedule.Line = keyLine(document.Content[0], "schedule")
+	notifyLine := keyLine(document.Content[0], "notify")
+	for i := range config.Notify {
+		config.Notify[i].Line = notifyLine
+	}
+	if config.Registry.Catalog == 0 {
+		config.Registry.Catalog = defaultCatalog
+	}
+	if config.Schedule.History == 0 {
+		config.Schedule.History = defaultHistory
+	}
+	return config, config.check()
+}
+
+// ParseRule decodes a single rule as strictly as Parse, from YAML or JSON.
+func ParseRule(content []byte) (Rule, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return Rule{}, err
+	}
+	if len(document.Content) == 0 {
+		return Rule{}, errors.New("empty rule")
+	}
+	if problems := unknownKeys(document.Content[0], ruleType, "rule"); len(problems) > 0 {
+		return Rule{}, errors.New(strings.Join(problems, "\n"))
+	}
+
+	var rule Rule
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rule); err != nil {
+		return Rule{}, err
+	}
+	if problems := rule.check(); len(problems) > 0 {
+		return Rule{}, errors.New(strings.Join(problems, "\n"))
+	}
+	return rule, nil
+}
+
+// ruleLines returns the line of each item of the rules sequence.
+func ruleLines(root *yaml.Node) []int {
+	var lines []int
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "rules" {
+			for _, item := range root.Content[i+1].Content {
+				lines = append(lines, item.Line)
+			}
+		}
+	}
+	return lines
+}
+
+// keyLine returns the line of key in the root mapping, 0 when missing.
+func keyLine(root *yaml.Node, key string) int {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			return root.Content[i].Line
+		}
+	}
+	return 0
+}
+
+func (c Config) check() error {
+	var problems []string
+	if c.Registry.URL == "" {
+		problems = append(problems, "registry.url is required")
+	}
+	for i, webhook := range c.Notify {
+		if webhook.URL == "" {
+			problems = append(problems, fmt.Sprintf("notify[%d].url is required", i))
+		}
+	}
+	if c.Schedule.Cron != "" && c.Schedule.Every != "" {
+		problems = append(problems, "schedule: set cron or every, not both")
+	}
+	if len(c.Rules) == 0 {
+		problems = append(problems, "at least one rule is required")
+	}
+	for i, rule := range c.Rules {
+		for _, problem := range rule.check() {
+			problems = append(problems, rule.Label(i)+": "+problem)
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (r Rule) check() []string {
+	var problems []string
+	if r.Image == "" {
+		problems = append(problems, "image is required")
+	}
+	for j, tag := range r.Tags {
+		if tag.Pattern == "" {
+			problems = append(problems, fmt.Sprintf("tags[%d].pattern is required", j))
+		}
+	}
+	return problems
+}
+
+// Label names the rule at index i in messages.
+func (r Rule) Label(i int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("rule \"%s\"", r.Name)
+	}
+	return fmt.Sprintf("rules[%d]", i)
+}
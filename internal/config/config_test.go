@@ -0,0 +1,139 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const validConfig = `
+registry:
+  url: https://registry.docker.example.com
+  insecure: true
+rules:
+  - name: branches
+    image: "r0mdau/*"
+    tags:
+      - pattern: "master-*"
+        keep: 10
+      - pattern: "pr-*"
+    keep: 2
+    exclude: ["hotfix-*"]
+    older-than: 30d
+  - image: r0mdau/nodejs
+    where: age(created) > 90d
+`
+
+func TestParse(t *testing.T) {
+	t.Run("Valid config is decoded with defaults", func(t *testing.T) {
+		config, err := Parse([]byte(validConfig))
+		require.NoError(t, err)
+
+		ten, two := 10, 2
+		require.Equal(t, Registry{URL: "https://registry.docker.example.com", Insecure: true, Catalog: 5000}, config.Registry)
+		require.Len(t, config.Rules, 2)
+		require.Equal(t, Rule{
+			Name:      "branches",
+			Image:     "r0mdau/*",
+			Tags:      []Tag{{"master-*", &ten}, {"pr-*", nil}},
+			Keep:      &two,
+			Exclude:   []string{"hotfix-*"},
+			OlderThan: "30d",
+			Line:      6,
+		}, config.Rules[0])
+		require.Equal(t, Schedule{History: 100}, config.Schedule)
+		require.Equal(t, "age(created) > 90d", config.Rules[1].Where)
+		require.Equal(t, 15, config.Rules[1].Line)
+	})
+
+	tdata := []struct {
+		testCase string
+		content  string
+		expected string
+	}{
+		{"Empty", "", "empty config"},
+		{"Typo in a rule key", "registry:\n  url: http://localhost\nrules:\n  - image: a\n    kep: 3\n", "line 5: unknown key \"kep\" in config.rules[0], did you mean \"keep\"?"},
+		{"Typo in a tag key", "registry:\n  url: http://localhost\nrules:\n  - image: a\n    tags:\n      - patern: \"*\"\n", "line 6: unknown key \"patern\" in config.rules[0].tags[0], did you mean \"pattern\"?"},
+		{"Unknown key", "registry:\n  url: http://localhost\n  password: secret\nrules: []\n", "line 3: unknown key \"password\" in config.registry, known keys are catalog, insecure, url"},
+		{"Wrong type", "registry:\n  url: http://localhost\nrules:\n  - image: a\n    keep: all\n", "yaml: unmarshal errors:\n  line 5: cannot unmarshal !!str `all` into int"},
+		{"Missing settings", "registry: {}\nrules:\n  - name: nameless\n    tags:\n      - keep: 1\n", "registry.url is required\nrule \"nameless\": image is required\nrule \"nameless\": tags[0].pattern is required"},
+		{"No rule", "registry:\n  url: http://localhost\n", "at least one rule is required"},
+		{"Webhook without url", "registry:\n  url: http://localhost\nnotify:\n  - format: slack\nrules:\n  - image: a\n", "notify[0].url is required"},
+		{"Cron and interval", "registry:\n  url: http://localhost\nschedule:\n  cron: \"@daily\"\n  every: 6h\nrules:\n  - image: a\n", "schedule: set cron or every, not both"},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			_, err := Parse([]byte(test.content))
+			require.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	two := 2
+	rule, err := ParseRule([]byte(`{"image": "r0mdau/*", "tags": [{"pattern": "pr-*", "keep": 2}], "older-than": "30d"}`))
+	require.NoError(t, err)
+	require.Equal(t, Rule{Image: "r0mdau/*", Tags: []Tag{{"pr-*", &two}}, OlderThan: "30d"}, rule)
+
+	_, err = ParseRule([]byte(`{"image": "r0mdau/*", "olderThan": "30d"}`))
+	require.EqualError(t, err, "line 1: unknown key \"olderThan\" in rule, did you mean \"older-than\"?")
+	_, err = ParseRule([]byte(`{"tags": [{"keep": 1}]}`))
+	require.EqualError(t, err, "image is required\ntags[0].pattern is required")
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("registry:\n  urls: http://localhost\n"), 0644))
+
+	_, err := Load(path)
+	require.EqualError(t, err, path+": line 2: unknown key \"urls\" in config.registry, did you mean \"url\"?")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestLoadFixture(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "fixture.yaml")
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	fixture, err := LoadFixture(write(`
+image: r0mdau/nodejs
+now: 2021-06-01T00:00:00Z
+tags:
+  - tag: master-1.2.0
+    digest: sha256:1
+    created: 2021-05-30T10:00:00Z
+    labels: {team: web}
+    size: 1024
+    platform: linux/amd64
+    expect: keep
+`))
+	require.NoError(t, err)
+	require.Equal(t, Fixture{
+		Image: "r0mdau/nodejs",
+		Now:   time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		Tags: []FixtureTag{{
+			Tag:      "master-1.2.0",
+			Digest:   "sha256:1",
+			Created:  time.Date(2021, 5, 30, 10, 0, 0, 0, time.UTC),
+			Labels:   map[string]string{"team": "web"},
+			Size:     1024,
+			Platform: "linux/amd64",
+			Expect:   ExpectKeep,
+		}},
+	}, fixture)
+
+	path := write("image: r0mdau/nodejs\ntags:\n  - tag: a\n    expect: keep\n  - tag: a\n    expect: remove\n  - expect: delete\n")
+	_, err = LoadFixture(path)
+	require.EqualError(t, err, path+": tags[1]: tag \"a\" is listed twice\ntags[1].expect must be keep or delete\ntags[2].tag is required")
+
+	path = write("image: r0mdau/nodejs\ntags:\n  - tag: a\n    expected: keep\n")
+	_, err = LoadFixture(path)
+	require.EqualError(t, err, path+": yaml: unmarshal errors:\n  line 4: field expected not found in type config.FixtureTag")
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	ExpectKeep   = "keep"
+	ExpectDelete = "delete"
+)
+
+// Fixture describes the tags of an image for policy tests, with what the
+// registry would tell about them and the expected decision:
+//
+//	image: r0mdau/nodejs
+//	now: 2021-06-01T00:00:00Z
+//	tags:
+//	  - tag: master-1.2.0
+//	    created: 2021-05-30T10:00:00Z
+//	    labels: {team: web}
+//	    expect: keep
+//
+// Now is the time the policy is evaluated at, the current time when unset.
+type Fixture struct {
+	Image string       `yaml:"image"`
+	Now   time.Time    `yaml:"now"`
+	Tags  []FixtureTag `yaml:"tags"`
+}
+
+type FixtureTag struct {
+	Tag      string            `yaml:"tag"`
+	Digest   string            `yaml:"digest"`
+	Created  time.Time         `yaml:"created"`
+	Labels   map[string]string `yaml:"labels"`
+	Size     int64             `yaml:"size"`
+	Platform string            `yaml:"platform"`
+	Expect   string            `yaml:"expect"`
+}
+
+// LoadFixture reads and strictly decodes the fixture file at path.
+func LoadFixture(path string) (Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	var fixture Fixture
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fixture); err != nil {
+		return Fixture{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := fixture.check(); err != nil {
+		return Fixture{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return fixture, nil
+}
+
+func (f Fixture) check() error {
+	var problems []string
+	if f.Image == "" {
+		problems = append(problems, "image is required")
+	}
+	if len(f.Tags) == 0 {
+		problems = append(problems, "at least one tag is required")
+	}
+	seen := make(map[string]bool)
+	for i, tag := range f.Tags {
+		switch {
+		case tag.Tag == "":
+			problems = append(problems, fmt.Sprintf("tags[%d].tag is required", i))
+		case seen[tag.Tag]:
+			problems = append(problems, fmt.Sprintf("tags[%d]: tag \"%s\" is listed twice", i, tag.Tag))
+		}
+		seen[tag.Tag] = true
+		if tag.Expect != ExpectKeep && tag.Expect != ExpectDelete {
+			problems = append(problems, fmt.Sprintf("tags[%d].expect must be %s or %s", i, ExpectKeep, ExpectDelete))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
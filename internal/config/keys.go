@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	configType = reflect.TypeOf(Config{})
+	ruleType   = reflect.TypeOf(Rule{})
+)
+
+// unknownKeys walks node along t and describes every mapping key t has no
+// field for, with the closest known key or the list of known keys.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var problems []string
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("line %d: unknown key \"%s\" in %s, %s", key.Line, key.Value, path, suggest(key.Value, fields)))
+				continue
+			}
+			problems = append(problems, unknownKeys(value, field, path+"."+key.Value)...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return problems
+}
+
+// yamlFields maps the yaml key of each field of t to its type.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// suggest proposes the known key closest to key, when it looks like a typo.
+func suggest(key string, fields map[string]reflect.Type) string {
+	var known []string
+	for name := range fields {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	best, bestDistance := "", len(key)/2+1
+	for _, name := range known {
+		if distance := levenshtein(key, name); distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("did you mean \"%s\"?", best)
+	}
+	return "known keys are " + strings.Join(known, ", ")
+}
+
+// levenshtein is the number of single character edits from a to b.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/expr"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/internal/notify"
+	"github.com/r0mdau/go-clean-docker-registry/internal/schedule"
+	"strings"
+	"time"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Problem is a finding of Lint on a rule.
+type Problem struct {
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s: %s: %s", p.Line, p.Rule, p.Severity, p.Message)
+}
+
+// Lint checks what Parse can't: invalid values are errors, conflicting,
+// unreachable and dangerous rules are warnings.
+func Lint(config Config) []Problem {
+	var problems []Problem
+	for _, message := range invalidSchedule(config.Schedule) {
+		problems = append(problems, Problem{SeverityError, config.Schedule.Line, "schedule", message})
+	}
+	for i, webhook := range config.Notify {
+		for _, message := range invalidWebhook(webhook) {
+			problems = append(problems, Problem{SeverityError, webhook.Line, fmt.Sprintf("notify[%d]", i), message})
+		}
+	}
+	for i, rule := range config.Rules {
+		report := func(severity, format string, args ...interface{}) {
+			problems = append(problems, Problem{severity, rule.Line, rule.Label(i), fmt.Sprintf(format, args...)})
+		}
+		for _, message := range invalidValues(rule) {
+			report(SeverityError, "%s", message)
+		}
+		for _, message := range shadowedTags(rule) {
+			report(SeverityWarning, "%s", message)
+		}
+		for j, other := range config.Rules[:i] {
+			for _, message := range conflicts(other, rule) {
+				report(SeverityWarning, "%s with %s", message, other.Label(j))
+			}
+			if shadows(other, rule) {
+				report(SeverityWarning, "unreachable, %s already deletes all its tags", other.Label(j))
+			}
+		}
+		if dangerous(rule) {
+			report(SeverityWarning, "deletes every tag of the matched images but the built-in protected ones, set a keep, an exclude or a condition")
+		}
+	}
+	return problems
+}
+
+func invalidSchedule(s Schedule) []string {
+	var messages []string
+	check := func(key string, err error) {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	if s.Cron != "" {
+		_, err := schedule.ParseCron(s.Cron)
+		check("cron", err)
+	}
+	if s.Every != "" {
+		_, err := filter.ParseAge(s.Every)
+		check("every", err)
+	}
+	if s.Jitter != "" {
+		_, err := filter.ParseAge(s.Jitter)
+		check("jitter", err)
+	}
+	if s.Window != "" {
+		_, err := schedule.ParseWindow(s.Window)
+		check("window", err)
+	}
+	if s.History < 0 {
+		messages = append(messages, "history: must be positive")
+	}
+	return messages
+}
+
+func invalidWebhook(webhook Webhook) []string {
+	var messages []string
+	if webhook.Format != "" && !contains(notify.Formats, webhook.Format) {
+		messages = append(messages, fmt.Sprintf("format: unknown format \"%s\", use one of %s", webhook.Format, strings.Join(notify.Formats, ", ")))
+	}
+	if webhook.Template != "" {
+		if _, err := notify.ParseTemplate(webhook.Template); err != nil {
+			messages = append(messages, fmt.Sprintf("template: %v", err))
+		}
+	}
+	if webhook.Retries != nil && *webhook.Retries < 0 {
+		messages = append(messages, "retries: must be positive")
+	}
+	if webhook.Timeout != "" {
+		if _, err := time.ParseDuration(webhook.Timeout); err != nil {
+			messages = append(messages, fmt.Sprintf("timeout: %v", err))
+		}
+	}
+	return messages
+}
+
+func invalidValues(rule Rule) []string {
+	var messages []string
+	check := func(key string, err error) {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	_, err := filter.NewGlobPattern(rule.Image)
+	check("image", err)
+	for j, tag := range rule.Tags {
+		if rule.Regex {
+			_, err = filter.NewRegexPattern(tag.Pattern)
+		} else {
+			_, err = filter.NewGlobPattern(tag.Pattern)
+		}
+		check(fmt.Sprintf("tags[%d].pattern", j), err)
+	}
+	_, err = filter.NewExcludeRules(rule.Exclude)
+	check("exclude", err)
+	if rule.Constraint != "" {
+		_, err = filter.NewConstraints(rule.Constraint)
+		check("constraint", err)
+	}
+	_, err = filter.NewSorter(rule.Sort, filter.SortData{})
+	check("sort", err)
+	if strings.HasPrefix(rule.Sort, "git-") && rule.GitRepo == "" {
+		messages = append(messages, "sort: "+rule.Sort+" needs git-repo")
+	}
+	check("keep-per", filter.CheckKeepPer(rule.KeepPer))
+	if rule.Where != "" {
+		_, err = expr.Parse(rule.Where)
+		check("where", err)
+	}
+	if rule.OlderThan != "" {
+		_, err = filter.ParseAge(rule.OlderThan)
+		check("older-than", err)
+	}
+	if rule.DeleteStaleGroups != "" {
+		_, err = filter.ParseAge(rule.DeleteStaleGroups)
+		check("delete-stale-groups", err)
+	}
+	if rule.DeleteGoneBranches && rule.GitRepo == "" && rule.GitRefs == "" {
+		messages = append(messages, "delete-gone-branches: needs git-repo or git-refs")
+	}
+	if rule.DeleteGoneBranches && len(rule.Tags) == 0 {
+		messages = append(messages, "delete-gone-branches: needs tags")
+	}
+	return messages
+}
+
+// keep returns the keep applied to the tag pattern at index j.
+func (r Rule) keep(j int) int {
+	switch {
+	case r.Tags[j].Keep != nil:
+		return *r.Tags[j].Keep
+	case r.Keep != nil:
+		return *r.Keep
+	}
+	return 0
+}
+
+// conditional tells if the rule only deletes some of the tags its patterns
+// match, whatever their keep.
+func (r Rule) conditional() bool {
+	return r.Constraint != "" || r.Where != "" || r.OlderThan != "" || r.GroupByPrefix || r.DeleteStaleGroups != ""
+}
+
+// deletesAll tells if the tag pattern at index j deletes every unprotected
+// tag it matches.
+func (r Rule) deletesAll(j int) bool {
+	return !r.Regex && !r.conditional() && r.keep(j) == 0
+}
+
+// shadowedTags reports the tag patterns of rule that can't select anything
+// more than the others, or anything at all.
+func shadowedTags(rule Rule) []string {
+	if rule.Regex {
+		return nil
+	}
+	var messages []string
+	for j, tag := range rule.Tags {
+		for _, exclude := range rule.Exclude {
+			if filter.GlobCovers(exclude, tag.Pattern) {
+				messages = append(messages, fmt.Sprintf("unreachable tag pattern \"%s\", its tags are all excluded by \"%s\"", tag.Pattern, exclude))
+			}
+		}
+		for k, other := range rule.Tags {
+			if k == j || !filter.GlobCovers(other.Pattern, tag.Pattern) {
+				continue
+			}
+			if other.Pattern == tag.Pattern && k < j {
+				messages = append(messages, fmt.Sprintf("tag pattern \"%s\" is listed twice", tag.Pattern))
+			} else if rule.deletesAll(k) && other.Pattern != tag.Pattern {
+				messages = append(messages, fmt.Sprintf("unreachable tag pattern \"%s\", \"%s\" already deletes all its tags", tag.Pattern, other.Pattern))
+			}
+		}
+	}
+	return messages
+}
+
+// conflicts reports the tag patterns rule shares with an earlier rule on the
+// same images with another keep, the lowest keep wins as tags are deleted
+// by any rule.
+func conflicts(earlier, rule Rule) []string {
+	if earlier.Image != rule.Image || earlier.Regex != rule.Regex {
+		return nil
+	}
+	var messages []string
+	for j, tag := range rule.Tags {
+		for k, other := range earlier.Tags {
+			if other.Pattern == tag.Pattern && earlier.keep(k) != rule.keep(j) {
+				messages = append(messages, fmt.Sprintf("tag pattern \"%s\" keeps %d but %d", tag.Pattern, rule.keep(j), earlier.keep(k)))
+			}
+		}
+	}
+	return messages
+}
+
+// shadows tells if the earlier rule already deletes every tag of rule: it
+// covers its images, deletes all tags of patterns covering each of its tag
+// patterns and excludes no more.
+func shadows(earlier, rule Rule) bool {
+	if rule.Regex || earlier.Regex || len(rule.Tags) == 0 || !filter.GlobCovers(earlier.Image, rule.Image) {
+		return false
+	}
+	for _, exclude := range earlier.Exclude {
+		if !contains(rule.Exclude, exclude) {
+			return false
+		}
+	}
+	for _, tag := range rule.Tags {
+		covered := false
+		for k, other := range earlier.Tags {
+			covered = covered || (earlier.deletesAll(k) && filter.GlobCovers(other.Pattern, tag.Pattern))
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// dangerous tells if the rule deletes all tags of its images: a wildcard
+// tag pattern, or none at all, without keep, exclude or condition.
+func dangerous(rule Rule) bool {
+	if len(rule.Exclude) > 0 || rule.conditional() || rule.DeleteGoneBranches {
+		return false
+	}
+	// without tag pattern keep is ignored and every tag is selected
+	if len(rule.Tags) == 0 {
+		return true
+	}
+	for j, tag := range rule.Tags {
+		if rule.keep(j) == 0 && matchesAll(tag.Pattern, rule.Regex) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(pattern string, regex bool) bool {
+	if regex {
+		switch pattern {
+		case ".*", "^.*", "^.*$", ".*$", ".+", "^.+$", "^":
+			return true
+		}
+		return false
+	}
+	return strings.Trim(pattern, "*") == ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func lintMessages(t *testing.T, rules string) []string {
+	config, err := Parse([]byte("registry:\n  url: http://localhost\nrules:\n" + rules))
+	require.NoError(t, err)
+	return problemStrings(Lint(config))
+}
+
+func TestLint(t *testing.T) {
+	t.Run("Valid rules have no problem", func(t *testing.T) {
+		require.Empty(t, lintMessages(t, `
+  - image: "r0mdau/*"
+    tags:
+      - pattern: "master-*"
+        keep: 10
+      - pattern: "pr-*"
+        keep: 2
+  - image: r0mdau/nodejs
+    tags:
+      - pattern: "*"
+    where: age(created) > 90d
+`))
+	})
+
+	t.Run("Invalid values are errors", func(t *testing.T) {
+		require.Equal(t, []string{
+			`line 5: rule "broken": error: tags[0].pattern: invalid tag regex: error parsing regexp: missing closing ): ` + "`rc(`",
+			`line 5: rule "broken": error: constraint: invalid semver constraint: Malformed constraint: <<2`,
+			`line 5: rule "broken": error: sort: unknown sort strategy "random", use one of semver, lexical, natural, calver, timestamp, created, git-time, git-topo`,
+			`line 5: rule "broken": error: where: 1:7: unexpected end of expression`,
+			`line 5: rule "broken": error: older-than: invalid age "soon", use a duration like 36h, 90d or 2w`,
+		}, lintMessages(t, `
+  - name: broken
+    image: r0mdau/nodejs
+    regex: true
+    tags:
+      - pattern: "rc("
+        keep: 1
+    constraint: "<<2"
+    sort: random
+    where: "tag =="
+    older-than: soon
+`))
+	})
+
+	t.Run("Gone branches need a git source and tags", func(t *testing.T) {
+		require.Equal(t, []string{
+			`line 5: rules[0]: error: delete-gone-branches: needs git-repo or git-refs`,
+			`line 5: rules[0]: error: delete-gone-branches: needs tags`,
+		}, lintMessages(t, `
+  - image: r0mdau/nodejs
+    delete-gone-branches: true
+`))
+	})
+
+	t.Run("Shadowed tag patterns and rules are unreachable", func(t *testing.T) {
+		require.Equal(t, []string{
+			`line 5: rules[0]: warning: unreachable tag pattern "hotfix-1.*", its tags are all excluded by "hotfix-*"`,
+			`line 5: rules[0]: warning: unreachable tag pattern "pr-1*", "pr-*" already deletes all its tags`,
+			`line 5: rules[0]: warning: tag pattern "master-*" is listed twice`,
+			`line 17: rules[1]: warning: unreachable, rules[0] already deletes all its tags`,
+		}, lintMessages(t, `
+  - image: "r0mdau/*"
+    tags:
+      - pattern: "hotfix-1.*"
+        keep: 1
+      - pattern: "pr-1*"
+        keep: 1
+      - pattern: "pr-*"
+      - pattern: "master-*"
+        keep: 3
+      - pattern: "master-*"
+        keep: 3
+    exclude: ["hotfix-*"]
+  - image: r0mdau/nodejs
+    tags:
+      - pattern: "pr-12*"
+        keep: 1
+    exclude: ["hotfix-*"]
+`))
+	})
+
+	t.Run("Same tag pattern with another keep conflicts", func(t *testing.T) {
+		require.Equal(t, []string{
+			`line 10: rules[1]: warning: tag pattern "master-*" keeps 2 but 10 with rule "first"`,
+		}, lintMessages(t, `
+  - name: first
+    image: r0mdau/nodejs
+    tags:
+      - pattern: "master-*"
+        keep: 10
+  - image: r0mdau/nodejs
+    keep: 2
+    tags:
+      - pattern: "master-*"
+`))
+	})
+
+	t.Run("Wildcards without keep, exclude or condition are dangerous", func(t *testing.T) {
+		dangerous := "warning: deletes every tag of the matched images but the built-in protected ones, set a keep, an exclude or a condition"
+		require.Equal(t, []string{
+			"line 5: rules[0]: " + dangerous,
+			"line 8: rules[1]: " + dangerous,
+			"line 10: rules[2]: " + dangerous,
+			"line 14: rules[3]: warning: unreachable, rules[0] already deletes all its tags",
+		}, lintMessages(t, `
+  - image: "*"
+    tags:
+      - pattern: "*"
+  - image: r0mdau/nodejs
+    keep: 3
+  - image: r0mdau/php
+    regex: true
+    tags:
+      - pattern: ".*"
+  - image: r0mdau/go
+    tags:
+      - pattern: "*"
+    exclude: ["v*"]
+`))
+	})
+
+	t.Run("Invalid schedule values are errors", func(t *testing.T) {
+		config, err := Parse([]byte("registry:\n  url: http://localhost\nschedule:\n  cron: \"61 * * * *\"\n  jitter: soon\n  window: 01:00\nrules:\n  - image: a\n    exclude: [\"v*\"]\n"))
+		require.NoError(t, err)
+		require.Equal(t, []Problem{
+			{SeverityError, 3, "schedule", "cron: invalid cron expression \"61 * * * *\", minute: 61 is out of 0-59"},
+			{SeverityError, 3, "schedule", "jitter: invalid age \"soon\", use a duration like 36h, 90d or 2w"},
+			{SeverityError, 3, "schedule", "window: invalid window \"01:00\", use HH:MM-HH:MM ie 01:00-05:00"},
+		}, Lint(config))
+	})
+
+	t.Run("Invalid webhooks are errors", func(t *testing.T) {
+		config, err := Parse([]byte("registry:\n  url: http://localhost\nnotify:\n  - url: http://hooks.example.com\n    format: teams\n    template: \"{{.Deleted\"\n    timeout: soon\nrules:\n  - image: a\n    exclude: [\"v*\"]\n"))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`line 3: notify[0]: error: format: unknown format "teams", use one of generic, slack`,
+			`line 3: notify[0]: error: template: template: webhook:1: unclosed action`,
+			`line 3: notify[0]: error: timeout: time: invalid duration "soon"`,
+		}, problemStrings(Lint(config)))
+	})
+}
+
+func problemStrings(problems []Problem) []string {
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.String())
+	}
+	return messages
+}
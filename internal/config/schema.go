@@ -0,0 +1,110 @@
+package config
+
+import (
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"github.com/r0mdau/go-clean-docker-registry/internal/notify"
+	"reflect"
+	"strings"
+)
+
+// descriptions documents the keys of the schema by path, items of lists
+// being named by the list key.
+var descriptions = map[string]string{
+	"registry":                            "Registry connection settings",
+	"registry.url":                        "Registry url ie https://registry.docker.example.com",
+	"registry.insecure":                   "Disable TLS cert verification",
+	"registry.catalog":                    "Number of repositories listed to match image globs, 5000 by default",
+	"schedule":                            "When the daemon command runs the rules, on cron or every",
+	"schedule.cron":                       "Cron expression of the runs ie \"0 3 * * *\" or @daily, local time",
+	"schedule.every":                      "Interval between runs ie 6h",
+	"schedule.jitter":                     "Maximum random delay added to each run ie 10m",
+	"schedule.window":                     "Daily maintenance window deletes are allowed in ie 01:00-05:00, local time",
+	"schedule.history":                    "Number of runs kept in memory, 100 by default",
+	"notify":                              "Webhooks the run and daemon commands post the summary of each run to",
+	"notify.url":                          "Webhook url",
+	"notify.format":                       "Payload, generic JSON summary by default or Slack message",
+	"notify.template":                     "Go text/template rendering the body, or the Slack text, from the summary",
+	"notify.retries":                      "Number of retries of a failed post, 3 by default",
+	"notify.timeout":                      "Timeout of each post ie 10s, 10s by default",
+	"rules":                               "Rules applied in one pass, a tag selected by several rules is deleted once",
+	"rules.name":                          "Name of the rule in messages",
+	"rules.image":                         "Image name, or glob of image names listed from the catalog",
+	"rules.tags":                          "Tag patterns, each one keeps its own newest tags, every tag is selected without",
+	"rules.tags.pattern":                  "Tag glob, or regular expression with regex",
+	"rules.tags.keep":                     "Number of newest tags of the pattern to keep, the rule keep by default",
+	"rules.regex":                         "Tag patterns are regular expressions, the (?P<version>...) group is used for sorting",
+	"rules.keep":                          "Number of newest tags to keep for the patterns without their own keep",
+	"rules.sort":                          "Tag sort strategy for keep",
+	"rules.keep-per":                      "Apply keep to each semver release line",
+	"rules.constraint":                    "Semver constraint on the tag version ie \"<2.0.0\"",
+	"rules.where":                         "Policy expression a tag must also satisfy ie \"age(created) > 90d && !release(version)\"",
+	"rules.exclude":                       "Tag globs to never delete, added to built-in latest, stable, prod-* and release tags",
+	"rules.no-builtin-protect":            "Don't protect latest, stable, prod-* and release tags, only the exclude globs",
+	"rules.older-than":                    "Only select tags whose image was created more than this age ago ie 30d",
+	"rules.delete-superseded-prereleases": "Also delete pre-releases once their final release is matched",
+	"rules.keep-prereleases":              "Also delete matched pre-releases except the newest N",
+	"rules.group-by-prefix":               "Split tags in <prefix>-<semver> and apply keep to each prefix group",
+	"rules.delete-stale-groups":           "Delete a whole prefix group when its newest tag is older than this age ie 30d",
+	"rules.delete-gone-branches":          "Also delete tags of branches that no longer exist",
+	"rules.git-repo":                      "Path of a local git repository",
+	"rules.git-refs":                      "File holding the output of git for-each-ref",
+}
+
+var required = map[string][]string{
+	"":           {"registry", "rules"},
+	"registry":   {"url"},
+	"rules":      {"image"},
+	"rules.tags": {"pattern"},
+}
+
+var enums = map[string][]string{
+	"rules.sort":     filter.SortStrategies,
+	"rules.keep-per": filter.KeepPerLines,
+	"notify.format":  notify.Formats,
+}
+
+// Schema returns the JSON Schema of config files, for editor completion.
+func Schema() map[string]interface{} {
+	schema := schemaOf(configType, "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "go-clean-docker-registry policy"
+	return schema
+}
+
+func schemaOf(t reflect.Type, path string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema := make(map[string]interface{})
+	if description, ok := descriptions[path]; ok {
+		schema["description"] = description
+	}
+	if enum, ok := enums[path]; ok {
+		schema["enum"] = enum
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for name, field := range yamlFields(t) {
+			properties[name] = schemaOf(field, strings.TrimPrefix(path+"."+name, "."))
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if keys := required[path]; len(keys) > 0 {
+			schema["required"] = keys
+		}
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = schemaOf(t.Elem(), path)
+		delete(schema["items"].(map[string]interface{}), "description")
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int:
+		schema["type"] = "integer"
+	}
+	return schema
+}
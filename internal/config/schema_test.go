@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	content, err := json.Marshal(Schema())
+	require.NoError(t, err)
+
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			Rules struct {
+				Type  string `json:"type"`
+				Items struct {
+					Required             []string `json:"required"`
+					AdditionalProperties bool     `json:"additionalProperties"`
+					Properties           map[string]struct {
+						Type        string   `json:"type"`
+						Enum        []string `json:"enum"`
+						Description string   `json:"description"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"rules"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(content, &schema))
+
+	require.Equal(t, []string{"registry", "rules"}, schema.Required)
+	rules := schema.Properties.Rules
+	require.Equal(t, "array", rules.Type)
+	require.Equal(t, []string{"image"}, rules.Items.Required)
+	require.False(t, rules.Items.AdditionalProperties)
+	require.Equal(t, "integer", rules.Items.Properties["keep"].Type)
+	require.Equal(t, "array", rules.Items.Properties["tags"].Type)
+	require.Contains(t, rules.Items.Properties["sort"].Enum, "calver")
+	for name, property := range rules.Items.Properties {
+		require.NotEmpty(t, property.Description, name)
+	}
+}
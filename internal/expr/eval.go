@@ -0,0 +1,273 @@
+package expr
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type node interface {
+	typ() Type
+	eval(vars Vars) (interface{}, error)
+}
+
+type literal struct {
+	t     Type
+	value interface{}
+}
+
+func (n *literal) typ() Type { return n.t }
+
+func (n *literal) eval(Vars) (interface{}, error) {
+	return n.value, nil
+}
+
+type variable struct {
+	name string
+	t    Type
+}
+
+func (n *variable) typ() Type { return n.t }
+
+func (n *variable) eval(vars Vars) (interface{}, error) {
+	value, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("%s is unknown", n.name)
+	}
+	if n.t == Number {
+		if size, ok := value.(int64); ok {
+			return float64(size), nil
+		}
+	}
+	return value, nil
+}
+
+// logical evaluates its right operand only when the left one doesn't decide.
+type logical struct {
+	or          bool
+	left, right node
+}
+
+func (n *logical) typ() Type { return Bool }
+
+func (n *logical) eval(vars Vars) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if left.(bool) == n.or {
+		return n.or, nil
+	}
+	return n.right.eval(vars)
+}
+
+type negation struct {
+	operand node
+}
+
+func (n *negation) typ() Type { return Bool }
+
+func (n *negation) eval(vars Vars) (interface{}, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return !value.(bool), nil
+}
+
+type compare struct {
+	operator    string
+	left, right node
+}
+
+func (n *compare) typ() Type { return Bool }
+
+func (n *compare) eval(vars Vars) (interface{}, error) {
+	left, right, err := evalBoth(vars, n.left, n.right)
+	if err != nil {
+		return nil, err
+	}
+	order := 0
+	switch a := left.(type) {
+	case bool:
+		if a != right.(bool) {
+			order = 1
+		}
+	case float64:
+		order = compareOrdered(a < right.(float64), a > right.(float64))
+	case string:
+		order = strings.Compare(a, right.(string))
+	case time.Duration:
+		order = compareOrdered(a < right.(time.Duration), a > right.(time.Duration))
+	case time.Time:
+		order = compareOrdered(a.Before(right.(time.Time)), a.After(right.(time.Time)))
+	}
+
+	switch n.operator {
+	case "==":
+		return order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	}
+	return order >= 0, nil
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+type arithmetic struct {
+	operator    string
+	left, right node
+	t           Type
+}
+
+func (n *arithmetic) typ() Type { return n.t }
+
+func (n *arithmetic) eval(vars Vars) (interface{}, error) {
+	left, right, err := evalBoth(vars, n.left, n.right)
+	if err != nil {
+		return nil, err
+	}
+	sign := 1
+	if n.operator == "-" {
+		sign = -1
+	}
+	switch a := left.(type) {
+	case float64:
+		return a + float64(sign)*right.(float64), nil
+	case time.Duration:
+		if t, ok := right.(time.Time); ok {
+			return t.Add(a), nil
+		}
+		return a + time.Duration(sign)*right.(time.Duration), nil
+	case time.Time:
+		if t, ok := right.(time.Time); ok {
+			return a.Sub(t), nil
+		}
+		return a.Add(time.Duration(sign) * right.(time.Duration)), nil
+	}
+	return nil, fmt.Errorf("unexpected %T operand", left)
+}
+
+type regexMatch struct {
+	operand    node
+	expression *regexp.Regexp
+	negate     bool
+}
+
+func (n *regexMatch) typ() Type { return Bool }
+
+func (n *regexMatch) eval(vars Vars) (interface{}, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return n.expression.MatchString(value.(string)) != n.negate, nil
+}
+
+type contains struct {
+	key, operand node
+}
+
+func (n *contains) typ() Type { return Bool }
+
+func (n *contains) eval(vars Vars) (interface{}, error) {
+	key, operand, err := evalBoth(vars, n.key, n.operand)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := operand.(map[string]string)[key.(string)]
+	return ok, nil
+}
+
+// index returns an empty string for missing keys.
+type index struct {
+	operand, key node
+}
+
+func (n *index) typ() Type { return String }
+
+func (n *index) eval(vars Vars) (interface{}, error) {
+	operand, key, err := evalBoth(vars, n.operand, n.key)
+	if err != nil {
+		return nil, err
+	}
+	return operand.(map[string]string)[key.(string)], nil
+}
+
+type function struct {
+	args    []Type
+	result  Type
+	usesNow bool
+	call    func(vars Vars, args []interface{}) interface{}
+}
+
+// functions are the built-in functions, none of them can fail.
+var functions = map[string]function{
+	// age is the time elapsed since a time
+	"age": {[]Type{Time}, Duration, true, func(vars Vars, args []interface{}) interface{} {
+		return vars["now"].(time.Time).Sub(args[0].(time.Time))
+	}},
+	// semver tells if a string is a semantic version
+	"semver": {[]Type{String}, Bool, false, func(vars Vars, args []interface{}) interface{} {
+		_, err := version.NewVersion(args[0].(string))
+		return err == nil
+	}},
+	// release tells if a string is a semantic version without pre-release
+	"release": {[]Type{String}, Bool, false, func(vars Vars, args []interface{}) interface{} {
+		v, err := version.NewVersion(args[0].(string))
+		return err == nil && v.Prerelease() == ""
+	}},
+	// prerelease tells if a string is a semantic version with a pre-release
+	"prerelease": {[]Type{String}, Bool, false, func(vars Vars, args []interface{}) interface{} {
+		v, err := version.NewVersion(args[0].(string))
+		return err == nil && v.Prerelease() != ""
+	}},
+	// lower converts a string to lower case
+	"lower": {[]Type{String}, String, false, func(vars Vars, args []interface{}) interface{} {
+		return strings.ToLower(args[0].(string))
+	}},
+}
+
+type call struct {
+	function function
+	args     []node
+}
+
+func (n *call) typ() Type { return n.function.result }
+
+func (n *call) eval(vars Vars) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return n.function.call(vars, args), nil
+}
+
+func evalBoth(vars Vars, left, right node) (interface{}, interface{}, error) {
+	a, err := left.eval(vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := right.eval(vars)
+	return a, b, err
+}
@@ -0,0 +1,122 @@
+// Package expr implements the retention policy expressions, a small language
+// evaluated against each tag like
+//
+//	age(created) > 90d && !release(version) && labels["keep"] != "true"
+//
+// Expressions can only read the variables of a tag and call the built-in
+// functions, they have no loops and no side effects. Types are checked when
+// parsing so that evaluation only fails on missing variables.
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Type int
+
+const (
+	Bool Type = iota
+	Number
+	String
+	Time
+	Duration
+	Map
+)
+
+func (t Type) String() string {
+	return [...]string{"bool", "number", "string", "time", "duration", "map"}[t]
+}
+
+// Variables are the variables of a tag and their type. now is the time the
+// expression is evaluated at.
+var Variables = map[string]Type{
+	"tag":      String,
+	"version":  String,
+	"prefix":   String,
+	"digest":   String,
+	"created":  Time,
+	"size":     Number,
+	"labels":   Map,
+	"platform": String,
+	"now":      Time,
+}
+
+// Vars holds the values of the variables for one tag: string, float64,
+// time.Time, time.Duration, bool or map[string]string.
+type Vars map[string]interface{}
+
+// Expression is a parsed and type checked boolean expression.
+type Expression struct {
+	source string
+	root   node
+	uses   map[string]bool
+}
+
+// Parse compiles source, errors are *SyntaxError holding the position of the
+// problem.
+func Parse(source string) (*Expression, error) {
+	p := &parser{source: source, uses: make(map[string]bool)}
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p.tokens = tokens
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source, root, p.uses}, nil
+}
+
+// Uses tells if the expression reads the variable name.
+func (e *Expression) Uses(name string) bool {
+	return e.uses[name]
+}
+
+// Eval evaluates the expression for one tag, now defaults to the current
+// time. It fails when a variable read by the expression is missing.
+func (e *Expression) Eval(vars Vars) (bool, error) {
+	if _, ok := vars["now"]; !ok && e.uses["now"] {
+		withNow := Vars{"now": time.Now()}
+		for name, value := range vars {
+			withNow[name] = value
+		}
+		vars = withNow
+	}
+	value, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// SyntaxError is a parse or type error at Line and Column, both starting at
+// 1.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+func newSyntaxError(source string, pos int, message string) *SyntaxError {
+	before := []rune(source)
+	if pos > len(before) {
+		pos = len(before)
+	}
+	lines := strings.Split(string(before[:pos]), "\n")
+	return &SyntaxError{
+		Line:    len(lines),
+		Column:  len([]rune(lines[len(lines)-1])) + 1,
+		Message: message,
+	}
+}
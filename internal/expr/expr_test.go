@@ -0,0 +1,123 @@
+package expr
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	vars := Vars{
+		"tag":      "master-1.2.0-rc.1",
+		"version":  "1.2.0-rc.1",
+		"prefix":   "master",
+		"digest":   "sha256:abc",
+		"created":  now.Add(-100 * 24 * time.Hour),
+		"size":     int64(300 << 20),
+		"labels":   map[string]string{"keep": "true", "team": "Web"},
+		"platform": "linux/amd64",
+		"now":      now,
+	}
+
+	tdata := []struct {
+		expression string
+		expected   bool
+	}{
+		{"true", true},
+		{"age(created) > 90d", true},
+		{"age(created) > 15w", false},
+		{"created < now - 90d && !release(version)", true},
+		{"created + 100d == now", true},
+		{"now - created >= 2400h", true},
+		{"prerelease(version) and semver(version)", true},
+		{"release(version) or prefix == \"develop\"", false},
+		{"not (size > 1GB)", true},
+		{"size >= 300MB && size < 300.5MB", true},
+		{"tag =~ '^master-' && tag !~ \"-linux$\"", true},
+		{"labels[\"keep\"] == \"true\"", true},
+		{"labels['missing'] == ''", true},
+		{"\"team\" in labels && lower(labels[\"team\"]) == \"web\"", true},
+		{"'missing' in labels", false},
+		{"platform == \"linux/arm64\" || digest != \"sha256:abc\"", false},
+		{"(version < \"2\") == true", true},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.expression, func(t *testing.T) {
+			expression, err := Parse(test.expression)
+			require.NoError(t, err)
+			actual, err := expression.Eval(vars)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestEvalMissingVariable(t *testing.T) {
+	expression, err := Parse("tag == 'latest' || age(created) > 30d")
+	require.NoError(t, err)
+
+	_, err = expression.Eval(Vars{"tag": "main"})
+	require.EqualError(t, err, "created is unknown")
+
+	actual, err := expression.Eval(Vars{"tag": "latest"})
+	require.NoError(t, err)
+	require.True(t, actual, "the right operand is not evaluated")
+
+	actual, err = expression.Eval(Vars{"tag": "main", "created": time.Now().Add(-31 * 24 * time.Hour)})
+	require.NoError(t, err)
+	require.True(t, actual, "now defaults to the current time")
+}
+
+func TestUses(t *testing.T) {
+	expression, err := Parse("age(created) > 1d && labels['a'] == ''")
+	require.NoError(t, err)
+	require.True(t, expression.Uses("created"))
+	require.True(t, expression.Uses("labels"))
+	require.True(t, expression.Uses("now"))
+	require.False(t, expression.Uses("digest"))
+}
+
+func TestParseErrors(t *testing.T) {
+	tdata := []struct {
+		expression string
+		expected   string
+	}{
+		{"", "1:1: unexpected end of expression"},
+		{"tag == ", "1:8: unexpected end of expression"},
+		{"tag == 'latest", "1:8: unterminated string"},
+		{"tag = 'latest'", "1:5: unexpected character '='"},
+		{"tags == 'latest'", "1:1: unknown variable \"tags\""},
+		{"size > 1MiB", "1:8: unknown unit \"MiB\", use s, m, h, d, w or B, KB, MB, GB, TB"},
+		{"age(created) > 30", "1:14: can't compare duration with number"},
+		{"created > 30d", "1:9: can't compare time with duration"},
+		{"tag", "1:1: expression is a string, expected a bool"},
+		{"tag == 'a' && size", "1:12: \"&&\" needs bool operands, got number"},
+		{"tag =~ '('", "1:8: invalid regex: error parsing regexp: missing closing ): `(`"},
+		{"tag =~ version", "1:5: \"=~\" needs a string and a string literal regex"},
+		{"age(tag) > 1d", "1:1: argument 1 of age must be a time, got string"},
+		{"exec('rm') == ''", "1:1: unknown function \"exec\""},
+		{"(tag == 'a'", "1:12: expected \")\", got end of expression"},
+		{"tag == 'a' tag", "1:12: unexpected \"tag\""},
+		{"tag == 'a' &&\n  sise > 1", "2:3: unknown variable \"sise\""},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Parse(test.expression)
+			require.EqualError(t, err, test.expected)
+			require.IsType(t, &SyntaxError{}, err)
+		})
+	}
+}
+
+func TestParseNestingLimit(t *testing.T) {
+	source := ""
+	for i := 0; i < 100; i++ {
+		source += "("
+	}
+	_, err := Parse(source + "true")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expression nested too deeply")
+}
@@ -0,0 +1,99 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // unquoted content of strings
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.value)
+	}
+	return fmt.Sprintf("\"%s\"", t.text)
+}
+
+// operators are tried in order so that two characters operators win.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "!", "<", ">", "+", "-", "(", ")", "[", "]", ","}
+
+// lex splits source in tokens, numbers keep their unit suffix like 90d or
+// 512MB.
+func lex(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		char := runes[i]
+		switch {
+		case unicode.IsSpace(char):
+			i++
+		case char == '"' || char == '\'':
+			start := i
+			var value strings.Builder
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, newSyntaxError(source, start, "unterminated string")
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					value.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == char {
+					break
+				}
+				value.WriteRune(runes[i])
+			}
+			i++
+			tokens = append(tokens, token{tokenString, string(runes[start:i]), value.String(), start})
+		case unicode.IsDigit(char):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), "", start})
+		case unicode.IsLetter(char) || char == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), "", start})
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, newSyntaxError(source, i, fmt.Sprintf("unexpected character %q", char))
+			}
+			tokens = append(tokens, token{tokenOperator, operator, "", i})
+			i += len([]rune(operator))
+		}
+	}
+	return append(tokens, token{tokenEOF, "", "", len(runes)}), nil
+}
@@ -0,0 +1,369 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxDepth bounds the nesting of expressions.
+const maxDepth = 64
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+var sizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// parser is a recursive descent parser, from the lowest precedence:
+//
+//	or         := and { ("||" | "or") and }
+//	and        := not { ("&&" | "and") not }
+//	not        := ("!" | "not") not | comparison
+//	comparison := additive [ ("==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "!~" | "in") additive ]
+//	additive   := postfix { ("+" | "-") postfix }
+//	postfix    := primary { "[" or "]" }
+//	primary    := number | string | "true" | "false" | variable | function "(" [ or { "," or } ] ")" | "(" or ")"
+type parser struct {
+	source string
+	tokens []token
+	next   int
+	depth  int
+	uses   map[string]bool
+}
+
+func (p *parser) parse() (node, error) {
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf(p.peek(), "unexpected %s", p.peek())
+	}
+	if root.typ() != Bool {
+		return nil, p.errorf(p.tokens[0], "expression is a %s, expected a bool", root.typ())
+	}
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// accept consumes the next token when it is one of the operators or
+// keywords.
+func (p *parser) accept(texts ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return t, false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return p.advance(), true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return p.errorf(p.peek(), "expected \"%s\", got %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) errorf(at token, format string, args ...interface{}) error {
+	return newSyntaxError(p.source, at.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, p.errorf(p.peek(), "expression nested too deeply")
+	}
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("||", "or")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBool(operator, left, right); err != nil {
+			return nil, err
+		}
+		left = &logical{or: true, left: left, right: right}
+	}
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("&&", "and")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkBool(operator, left, right); err != nil {
+			return nil, err
+		}
+		left = &logical{or: false, left: left, right: right}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	operator, ok := p.accept("!", "not")
+	if !ok {
+		return p.comparison()
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, p.errorf(operator, "expression nested too deeply")
+	}
+	operand, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBool(operator, operand); err != nil {
+		return nil, err
+	}
+	return &negation{operand}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "=~", "!~", "in")
+	if !ok {
+		return left, nil
+	}
+	rightToken := p.peek()
+	right, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator.text {
+	case "=~", "!~":
+		pattern, ok := right.(*literal)
+		if left.typ() != String || !ok || pattern.t != String {
+			return nil, p.errorf(operator, "\"%s\" needs a string and a string literal regex", operator.text)
+		}
+		compiled, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, p.errorf(rightToken, "invalid regex: %v", err)
+		}
+		return &regexMatch{left, compiled, operator.text == "!~"}, nil
+	case "in":
+		if left.typ() != String || right.typ() != Map {
+			return nil, p.errorf(operator, "\"in\" needs a string and a map, got %s and %s", left.typ(), right.typ())
+		}
+		return &contains{left, right}, nil
+	}
+
+	if left.typ() != right.typ() {
+		return nil, p.errorf(operator, "can't compare %s with %s", left.typ(), right.typ())
+	}
+	if left.typ() == Map || (left.typ() == Bool && operator.text != "==" && operator.text != "!=") {
+		return nil, p.errorf(operator, "\"%s\" is not defined on %s", operator.text, left.typ())
+	}
+	return &compare{operator.text, left, right}, nil
+}
+
+func (p *parser) additive() (node, error) {
+	left, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		t, ok := arithmeticType(operator.text, left.typ(), right.typ())
+		if !ok {
+			return nil, p.errorf(operator, "\"%s\" is not defined on %s and %s", operator.text, left.typ(), right.typ())
+		}
+		left = &arithmetic{operator.text, left, right, t}
+	}
+}
+
+// arithmeticType returns the type of left operator right: numbers and
+// durations add up, durations shift times and times subtract to a duration.
+func arithmeticType(operator string, left, right Type) (Type, bool) {
+	switch {
+	case left == Number && right == Number:
+		return Number, true
+	case left == Duration && right == Duration:
+		return Duration, true
+	case left == Time && right == Duration:
+		return Time, true
+	case left == Duration && right == Time && operator == "+":
+		return Time, true
+	case left == Time && right == Time && operator == "-":
+		return Duration, true
+	}
+	return Bool, false
+}
+
+func (p *parser) postfix() (node, error) {
+	operand, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		bracket, ok := p.accept("[")
+		if !ok {
+			return operand, nil
+		}
+		key, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		if operand.typ() != Map || key.typ() != String {
+			return nil, p.errorf(bracket, "can't index %s with %s", operand.typ(), key.typ())
+		}
+		operand = &index{operand, key}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenNumber:
+		return p.number(t)
+	case tokenString:
+		return &literal{String, t.value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return &literal{Bool, t.text == "true"}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.call(t)
+		}
+		variableType, ok := Variables[t.text]
+		if !ok {
+			return nil, p.errorf(t, "unknown variable \"%s\"", t.text)
+		}
+		p.uses[t.text] = true
+		return &variable{t.text, variableType}, nil
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+func (p *parser) number(t token) (node, error) {
+	digits := strings.TrimRightFunc(t.text, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	unit := t.text[len(digits):]
+	value, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return nil, p.errorf(t, "invalid number %s", t)
+	}
+	if unit == "" {
+		return &literal{Number, value}, nil
+	}
+	if duration, ok := durationUnits[unit]; ok {
+		return &literal{Duration, time.Duration(value * float64(duration))}, nil
+	}
+	if size, ok := sizeUnits[unit]; ok {
+		return &literal{Number, value * size}, nil
+	}
+	return nil, p.errorf(t, "unknown unit \"%s\", use s, m, h, d, w or B, KB, MB, GB, TB", unit)
+}
+
+func (p *parser) call(name token) (node, error) {
+	f, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function \"%s\"", name.text)
+	}
+	var args []node
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) != len(f.args) {
+		return nil, p.errorf(name, "%s expects %d argument(s), got %d", name.text, len(f.args), len(args))
+	}
+	for i, arg := range args {
+		if arg.typ() != f.args[i] {
+			return nil, p.errorf(name, "argument %d of %s must be a %s, got %s", i+1, name.text, f.args[i], arg.typ())
+		}
+	}
+	if f.usesNow {
+		p.uses["now"] = true
+	}
+	return &call{f, args}, nil
+}
+
+func (p *parser) checkBool(operator token, operands ...node) error {
+	for _, operand := range operands {
+		if operand.typ() != Bool {
+			return p.errorf(operator, "\"%s\" needs bool operands, got %s", operator.text, operand.typ())
+		}
+	}
+	return nil
+}
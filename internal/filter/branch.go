@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// GitRefs holds what remains in a git repository, see internal/git.
+type GitRefs struct {
+	// Branches are the existing branch names as tag prefixes
+	Branches map[string]bool
+	// Commits are the full SHAs reachable from a branch indexed by their
+	// 7 first characters, nil when history is unknown
+	Commits map[string][]string
+	// Known are all the commits of the repository, reachable or not, indexed
+	// the same way. A commit missing from them may just not be fetched yet
+	Known map[string][]string
+}
+
+var shaRegexp = regexp.MustCompile(`(?:^|[\-_.])([0-9a-f]{7,40})(?:[\-_.]|$)`)
+
+// EmbeddedSHA finds a short or full commit SHA in tag, ie main-3f2a9c1. At
+// least one letter is required so build numbers are not taken for SHAs.
+func EmbeddedSHA(tag string) (string, bool) {
+	for _, submatches := range shaRegexp.FindAllStringSubmatch(tag, -1) {
+		if strings.ContainsAny(submatches[1], "abcdef") {
+			return submatches[1], true
+		}
+	}
+	return "", false
+}
+
+// BranchPrefix converts a branch name to the form used in tags, characters
+// not allowed in a tag become dashes: feature/login gives feature-login.
+func BranchPrefix(branch string) string {
+	return invalidTagChars.ReplaceAllString(branch, "-")
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// Reachable tells if sha, short or full, is a commit reachable from a
+// branch.
+func (g GitRefs) Reachable(sha string) bool {
+	return hasCommit(g.Commits, sha)
+}
+
+// hasCommit tells if sha, short or full, is one of commits.
+func hasCommit(commits map[string][]string, sha string) bool {
+	if len(sha) < 7 {
+		return false
+	}
+	for _, commit := range commits[sha[:7]] {
+		if strings.HasPrefix(commit, sha) {
+			return true
+		}
+	}
+	return false
+}
+
+// gone tells if tag belongs to a branch that no longer exists, or embeds a
+// commit of the repository no longer reachable when history is known. Tags
+// without a recognizable <branch>-<semver> or <branch>-<sha> form are never
+// gone, nor tags of a commit unknown to the repository.
+func (g GitRefs) gone(tag string) bool {
+	sha, hasSHA := EmbeddedSHA(tag)
+	if hasSHA && g.Commits != nil && !g.Reachable(sha) {
+		return hasCommit(g.Known, sha)
+	}
+	if g.Branches[tag] {
+		return false
+	}
+	for i := len(tag) - 1; i > 0; i-- {
+		if tag[i] == '-' && g.Branches[tag[:i]] {
+			return false
+		}
+	}
+	_, _, hasVersion := SplitPrefix(tag)
+	return hasVersion || (hasSHA && !strings.HasPrefix(tag, sha))
+}
+
+func (g GitRefs) selectGone(matches []Match) []Match {
+	var result []Match
+	for _, m := range matches {
+		if g.gone(m.Tag) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Commit is a commit of the git history, Order is its topological position
+// counted from the oldest commit.
+type Commit struct {
+	SHA   string
+	Time  time.Time
+	Order int
+}
+
+// GitHistory holds commits indexed by the 7 first characters of their SHA.
+type GitHistory map[string][]Commit
+
+// Resolve finds the commit whose SHA is embedded in version.
+func (h GitHistory) Resolve(version string) (Commit, bool) {
+	sha, ok := EmbeddedSHA(version)
+	if !ok {
+		return Commit{}, false
+	}
+	for _, commit := range h[sha[:7]] {
+		if strings.HasPrefix(commit.SHA, sha) {
+			return commit, true
+		}
+	}
+	return Commit{}, false
+}
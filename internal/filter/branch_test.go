@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEmbeddedSHA(t *testing.T) {
+	tdata := []struct {
+		tag        string
+		expectedOk bool
+		expected   string
+	}{
+		{"main-3f2a9c1", true, "3f2a9c1"},
+		{"3f2a9c1", true, "3f2a9c1"},
+		{"feature-login-3f2a9c1-amd64", true, "3f2a9c1"},
+		{"build-4516033", false, ""},
+		{"master-1.0.0", false, ""},
+		{"deadbeef", true, "deadbeef"},
+		{"feature-deadbee", true, "deadbee"},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.tag, func(t *testing.T) {
+			actual, ok := EmbeddedSHA(test.tag)
+			require.Equal(t, test.expectedOk, ok)
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestGitRefs(t *testing.T) {
+	refs := GitRefs{
+		Branches: map[string]bool{"main": true, "feature-login": true, BranchPrefix("fix/crash"): true},
+		Commits: map[string][]string{
+			"3f2a9c1": {"3f2a9c1b0000000000000000000000000000000"},
+			"aaaaaaa": {"aaaaaaa1000000000000000000000000000000", "aaaaaaa2000000000000000000000000000000"},
+		},
+		Known: map[string][]string{
+			"3f2a9c1": {"3f2a9c1b0000000000000000000000000000000"},
+			"aaaaaaa": {"aaaaaaa1000000000000000000000000000000", "aaaaaaa2000000000000000000000000000000"},
+			"bbbbbbb": {"bbbbbbb1000000000000000000000000000000"},
+		},
+	}
+	tags := []string{"main-1.0.0", "main-3f2a9c1", "main-bbbbbbb", "feature-login-2.0.0", "feature-old-2.0.0", "fix-crash-0.1.0", "feature-gone-aaaaaaa2", "aaaaaaa1", "ccccccc", "latest", "pr-12"}
+
+	t.Run("Select tags of deleted branches and unreachable commits", func(t *testing.T) {
+		selector := newTestSelector(t, "*", len(tags))
+		selector.Git = &refs
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"main-bbbbbbb", "feature-old-2.0.0", "feature-gone-aaaaaaa2"}, selected)
+	})
+
+	t.Run("Commits unknown to the repository are kept", func(t *testing.T) {
+		selector := newTestSelector(t, "*", len(tags))
+		selector.Git = &GitRefs{Branches: refs.Branches, Commits: refs.Commits}
+		selected, _ := Select([]string{"main-bbbbbbb", "ccccccc", "main-3f2a9c1"}, []Selector{selector}, nil)
+		require.Empty(t, selected)
+	})
+
+	t.Run("Without history only branch prefixes are checked", func(t *testing.T) {
+		selector := newTestSelector(t, "*", len(tags))
+		selector.Git = &GitRefs{Branches: refs.Branches}
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"feature-old-2.0.0", "feature-gone-aaaaaaa2"}, selected)
+	})
+}
@@ -0,0 +1,144 @@
+package filter
+
+// Reasons of the decisions of Explain.
+const (
+	ReasonNotMatched = "not matched"
+	ReasonProtected  = "protected"
+	// ReasonNewest is a match kept as one of the Keep newest
+	ReasonNewest = "newest"
+	// ReasonOlder is a match older than the Keep newest
+	ReasonOlder      = "older"
+	ReasonPrerelease = "prerelease"
+	ReasonStale      = "stale group"
+	ReasonGone       = "gone branch"
+	// ReasonUngrouped is a match without prefix group, never selected
+	ReasonUngrouped = "no prefix group"
+	// ReasonUnordered is a match whose commit is missing from the git
+	// history, never selected by keep
+	ReasonUnordered = "commit not found"
+	// ReasonAll is an unprotected tag when there is no selector
+	ReasonAll = "all tags"
+)
+
+// Decision explains what Select does with a tag.
+type Decision struct {
+	Tag    string `json:"tag"`
+	Delete bool   `json:"delete"`
+	Reason string `json:"reason"`
+	// Selector is the index of the selector deleting the tag, or of the
+	// first one matching it when kept, -1 when none matches
+	Selector int `json:"selector"`
+	// Version is the segment of the tag the selector sorts on
+	Version string `json:"version,omitempty"`
+	// Rank is the position of the tag among the sorted unprotected matches
+	// of the selector, or of its prefix group, 1 being the newest
+	Rank int `json:"rank,omitempty"`
+	// Rule is the rule protecting the tag
+	Rule string `json:"rule,omitempty"`
+}
+
+// Explain returns the decision of Select for each tag, in the order of tags.
+func Explain(tags []string, selectors []Selector, rules []Rule) []Decision {
+	decisions := make([]Decision, len(tags))
+	index := make(map[string]int)
+	for i, tag := range tags {
+		decisions[i] = Decision{Tag: tag, Reason: ReasonNotMatched, Selector: -1}
+		index[tag] = i
+	}
+
+	if len(selectors) == 0 {
+		for i, tag := range tags {
+			if rule, ok := matchRule(tag, rules); ok {
+				decisions[i].Reason, decisions[i].Rule = ReasonProtected, rule
+				continue
+			}
+			decisions[i].Delete, decisions[i].Reason = true, ReasonAll
+		}
+		return decisions
+	}
+
+	for s, selector := range selectors {
+		matches, protected := protectMatches(selector.matchTags(tags), rules)
+		for _, p := range protected {
+			d := &decisions[index[p.Tag]]
+			if d.Selector == -1 {
+				d.Reason, d.Rule, d.Selector = ReasonProtected, p.Rule, s
+			}
+		}
+		for _, m := range selector.explain(matches) {
+			d := &decisions[index[m.Tag]]
+			if d.Delete || (d.Selector != -1 && !m.Delete) {
+				continue
+			}
+			m.Selector = s
+			*d = m
+		}
+	}
+	return decisions
+}
+
+// explain decides on each unprotected match of the selector like Select,
+// in the order of matches.
+func (s Selector) explain(matches []Match) []Decision {
+	decisions := make([]Decision, len(matches))
+	index := make(map[string]int)
+	for i, m := range matches {
+		decisions[i] = Decision{Tag: m.Tag, Reason: ReasonNewest, Version: m.Version}
+		index[m.Tag] = i
+	}
+	add := func(dropped []Match, reason string) {
+		for _, m := range dropped {
+			if d := &decisions[index[m.Tag]]; !d.Delete {
+				d.Delete, d.Reason = true, reason
+			}
+		}
+	}
+	explainGroup := func(group []Match) {
+		if s.Sorter.keepsUnorderable() {
+			for _, m := range group {
+				decisions[index[m.Tag]].Reason = ReasonUnordered
+			}
+			group = s.Sorter.orderable(group)
+			for _, m := range group {
+				decisions[index[m.Tag]].Reason = ReasonNewest
+			}
+		}
+		sorted := s.Sorter.Sort(group)
+		for i, m := range sorted {
+			decisions[index[m.Tag]].Rank = len(sorted) - i
+		}
+		if s.GroupByPrefix && s.isStale(group) {
+			add(group, ReasonStale)
+			return
+		}
+		if s.KeepPer != "" {
+			add(dropNewestPerLine(sorted, s.Keep, s.KeepPer), ReasonOlder)
+		} else {
+			add(dropNewest(sorted, s.Keep), ReasonOlder)
+		}
+		if s.Prereleases.enabled() {
+			add(s.Prereleases.selectPrereleases(sorted), ReasonPrerelease)
+		}
+	}
+
+	if s.GroupByPrefix {
+		for _, d := range decisions {
+			if _, _, ok := SplitPrefix(d.Tag); !ok {
+				decisions[index[d.Tag]].Reason = ReasonUngrouped
+			}
+		}
+		prefixes, groups := prefixGroups(matches)
+		for _, prefix := range prefixes {
+			for _, m := range groups[prefix] {
+				decisions[index[m.Tag]].Version = m.Version
+			}
+			explainGroup(groups[prefix])
+		}
+	} else {
+		explainGroup(matches)
+	}
+	if s.Git != nil {
+		add(s.Git.selectGone(matches), ReasonGone)
+	}
+	return decisions
+}
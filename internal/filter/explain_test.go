@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// deleted returns the tags of decisions to delete.
+func deleted(decisions []Decision) []string {
+	var tags []string
+	for _, d := range decisions {
+		if d.Delete {
+			tags = append(tags, d.Tag)
+		}
+	}
+	return tags
+}
+
+func TestExplain(t *testing.T) {
+	tags := []string{"latest", "master-1.0.1", "develop-2.0.0", "master-0.9.2", "pr-12", "master-1.0.0", "develop-2.1.0", "pr-13"}
+
+	t.Run("Every tag gets a decision", func(t *testing.T) {
+		rules, err := NewExcludeRules([]string{"master-1.0.1"})
+		require.NoError(t, err)
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 1),
+			newTestSelector(t, "*-0.9.2", 0),
+		}
+		require.Equal(t, []Decision{
+			{Tag: "latest", Reason: ReasonNotMatched, Selector: -1},
+			{Tag: "master-1.0.1", Reason: ReasonProtected, Selector: 0, Rule: "exclude:master-1.0.1"},
+			{Tag: "develop-2.0.0", Reason: ReasonNotMatched, Selector: -1},
+			{Tag: "master-0.9.2", Delete: true, Reason: ReasonOlder, Selector: 0, Version: "0.9.2", Rank: 2},
+			{Tag: "pr-12", Reason: ReasonNotMatched, Selector: -1},
+			{Tag: "master-1.0.0", Reason: ReasonNewest, Selector: 0, Version: "1.0.0", Rank: 1},
+			{Tag: "develop-2.1.0", Reason: ReasonNotMatched, Selector: -1},
+			{Tag: "pr-13", Reason: ReasonNotMatched, Selector: -1},
+		}, Explain(tags, selectors, rules))
+	})
+
+	t.Run("A later selector deletes a kept tag", func(t *testing.T) {
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 3),
+			newTestSelector(t, "*-1.0.1", 0),
+		}
+		decisions := Explain(tags, selectors, nil)
+		require.Equal(t, Decision{Tag: "master-1.0.1", Delete: true, Reason: ReasonOlder, Selector: 1, Version: "master", Rank: 1}, decisions[1])
+		require.Equal(t, Decision{Tag: "master-0.9.2", Reason: ReasonNewest, Selector: 0, Version: "0.9.2", Rank: 3}, decisions[3])
+	})
+
+	t.Run("Without selector every unprotected tag is deleted", func(t *testing.T) {
+		decisions := Explain(tags, nil, DefaultProtectRules())
+		require.Equal(t, Decision{Tag: "latest", Reason: ReasonProtected, Selector: -1, Rule: "builtin:latest"}, decisions[0])
+		require.Equal(t, Decision{Tag: "pr-12", Delete: true, Reason: ReasonAll, Selector: -1}, decisions[4])
+	})
+
+	t.Run("Pre-releases and groups", func(t *testing.T) {
+		tags := []string{"master-1.0.0", "master-1.1.0-rc.1", "master-1.1.0", "feature-a-0.1.0", "pr-12"}
+		selector := newTestSelector(t, "*", 2)
+		selector.GroupByPrefix = true
+		selector.Prereleases = Prereleases{DeleteSuperseded: true}
+		selector.StaleAfter = 24 * time.Hour
+		selector.Now = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+		selector.Created = map[string]time.Time{"feature-a-0.1.0": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+		decisions := Explain(tags, []Selector{selector}, nil)
+		require.Equal(t, Decision{Tag: "master-1.0.0", Delete: true, Reason: ReasonOlder, Version: "1.0.0", Rank: 3}, decisions[0])
+		require.Equal(t, Decision{Tag: "master-1.1.0-rc.1", Delete: true, Reason: ReasonPrerelease, Version: "1.1.0-rc.1", Rank: 2}, decisions[1])
+		require.Equal(t, Decision{Tag: "master-1.1.0", Reason: ReasonNewest, Version: "1.1.0", Rank: 1}, decisions[2])
+		require.Equal(t, Decision{Tag: "feature-a-0.1.0", Delete: true, Reason: ReasonStale, Version: "0.1.0", Rank: 1}, decisions[3])
+		require.Equal(t, Decision{Tag: "pr-12", Reason: ReasonUngrouped, Version: "pr-12"}, decisions[4])
+
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.ElementsMatch(t, selected, deleted(decisions))
+	})
+
+	t.Run("Decisions agree with Select", func(t *testing.T) {
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 1),
+			newTestSelector(t, "develop-*", 0),
+			newTestSelector(t, "pr-*", 1),
+		}
+		selected, _ := Select(tags, selectors, DefaultProtectRules())
+		require.ElementsMatch(t, selected, deleted(Explain(tags, selectors, DefaultProtectRules())))
+	})
+}
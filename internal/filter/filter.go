@@ -1,45 +1,30 @@
 package filter
 
-import (
-	"github.com/hashicorp/go-version"
-	"regexp"
-	"sort"
-)
-
 func MatchAndSortImageTags(tags []string, imageTag string) ([]string, error) {
-	var imageTagsToDelete []string
-	// todo work with this kind of wildcard : *-master
-	tagWildcard := imageTag[len(imageTag)-1:]
-
-	mapping := make(map[string]string)
+	pattern, err := NewGlobPattern(imageTag)
+	if err != nil {
+		return nil, err
+	}
+	return SortMatchingTags(tags, pattern, Sorter{}), nil
+}
 
-	imageBaseName := imageTag[:len(imageTag)-1]
-	var versions []*version.Version
+// SortMatchingTags returns the tags matching pattern, tags the sorter can't
+// order first in registry order, then the others from oldest to newest.
+func SortMatchingTags(tags []string, pattern Pattern, sorter Sorter) []string {
+	var imageTagsToDelete []string
+	for _, m := range sorter.Sort(MatchTags(tags, pattern)) {
+		imageTagsToDelete = append(imageTagsToDelete, m.Tag)
+	}
+	return imageTagsToDelete
+}
 
+// MatchTags returns the tags matching pattern with their version segment.
+func MatchTags(tags []string, pattern Pattern) []Match {
+	var matches []Match
 	for _, tag := range tags {
-		if tag == imageTag && tagWildcard != "*" {
-			imageTagsToDelete = append(imageTagsToDelete, tag)
-			return imageTagsToDelete, nil
-		}
-
-		if ok, _ := regexp.MatchString("^"+imageTag, tag); ok {
-			identifier := tag[len(imageBaseName):]
-
-			v, err := version.NewVersion(identifier)
-			if err != nil {
-				// appending matching tags not semver(sioned)
-				imageTagsToDelete = append(imageTagsToDelete, tag)
-				continue
-			}
-			versions = append(versions, v)
-			mapping[v.String()] = tag
+		if identifier, ok := pattern.Match(tag); ok {
+			matches = append(matches, Match{tag, identifier})
 		}
 	}
-
-	// sort versions from oldest to newest
-	sort.Sort(version.Collection(versions))
-	for _, value := range versions {
-		imageTagsToDelete = append(imageTagsToDelete, mapping[value.String()])
-	}
-	return imageTagsToDelete, nil
+	return matches
 }
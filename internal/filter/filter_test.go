@@ -6,7 +6,7 @@ import (
 )
 
 func TestMatchAndSortImageTags(t *testing.T) {
-	tags := []string{"build-4516033", "build-4516054", "build-4516548", "test-6.0.0", "test-6.4.1", "master-1.0.0", "master-1.0.1", "master-0.9.2"}
+	tags := []string{"build-4516033", "build-4516054", "build-4516548", "test-6.0.0", "test-6.4.1", "master-1.0.0", "master-1.0.1", "master-0.9.2", "2.1.0-master", "1.10.0-master", "1.9.0-master"}
 
 	tdata := []struct {
 		testCase         string
@@ -32,6 +32,12 @@ func TestMatchAndSortImageTags(t *testing.T) {
 			tag:              "master-1.0.1",
 			expectedEquality: true,
 		},
+		{
+			testCase:         "Can match leading * wildcard and sort on prefix version",
+			expected:         []string{"1.9.0-master", "1.10.0-master", "2.1.0-master"},
+			tag:              "*-master",
+			expectedEquality: true,
+		},
 		{
 			testCase:         "Return empty if no match",
 			expected:         []string(nil),
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SplitPrefix splits a <prefix>-<semver> tag, ie feature-login-1.2.3-rc.1 in
+// feature-login and 1.2.3-rc.1. The version is the first part after a dash
+// whose release has at least major.minor, so feature-1-2-1.0.0 gives
+// feature-1-2 and 1.0.0.
+func SplitPrefix(tag string) (string, string, bool) {
+	for i, char := range tag {
+		if char != '-' {
+			continue
+		}
+		remainder := tag[i+1:]
+		core := strings.SplitN(strings.TrimPrefix(remainder, "v"), "-", 2)[0]
+		if !strings.Contains(core, ".") {
+			continue
+		}
+		if _, err := version.NewVersion(remainder); err == nil {
+			return tag[:i], remainder, true
+		}
+	}
+	return "", "", false
+}
+
+// prefixGroups splits matches in prefix groups, in the order of their first
+// tag, each match with the version after its prefix. Tags that can't be
+// split are left out.
+func prefixGroups(matches []Match) ([]string, map[string][]Match) {
+	var prefixes []string
+	groups := make(map[string][]Match)
+	for _, m := range matches {
+		prefix, identifier, ok := SplitPrefix(m.Tag)
+		if !ok {
+			continue
+		}
+		if _, ok := groups[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+		groups[prefix] = append(groups[prefix], Match{m.Tag, identifier})
+	}
+	return prefixes, groups
+}
+
+// dropGroups applies the selection to each prefix group of matches. Tags
+// that can't be split are never selected.
+func (s Selector) dropGroups(matches []Match) []Match {
+	prefixes, groups := prefixGroups(matches)
+	var dropped []Match
+	for _, prefix := range prefixes {
+		if s.isStale(groups[prefix]) {
+			dropped = append(dropped, groups[prefix]...)
+			continue
+		}
+		dropped = append(dropped, s.drop(groups[prefix])...)
+	}
+	return dropped
+}
+
+// isStale tells if the newest tag of group was created before the cutoff,
+// groups without any known creation time are never stale.
+func (s Selector) isStale(group []Match) bool {
+	if s.StaleAfter <= 0 {
+		return false
+	}
+	var newest time.Time
+	for _, m := range group {
+		if created, ok := s.Created[m.Tag]; ok && created.After(newest) {
+			newest = created
+		}
+	}
+	now := s.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return !newest.IsZero() && newest.Before(now.Add(-s.StaleAfter))
+}
+
+// ParseAge parses a Go duration, also accepting days and weeks like 90d or
+// 2w.
+func ParseAge(age string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(age, suffix) {
+			count, err := strconv.ParseFloat(strings.TrimSuffix(age, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid age \"%s\"", age)
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age \"%s\", use a duration like 36h, 90d or 2w", age)
+	}
+	return duration, nil
+}
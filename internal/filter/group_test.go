@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSplitPrefix(t *testing.T) {
+	tdata := []struct {
+		tag             string
+		expectedOk      bool
+		expectedPrefix  string
+		expectedVersion string
+	}{
+		{"master-1.2.3", true, "master", "1.2.3"},
+		{"feature-login-1.2.3-rc.1", true, "feature-login", "1.2.3-rc.1"},
+		{"feature-1-2-1.0.0", true, "feature-1-2", "1.0.0"},
+		{"fix-v2.0.0", true, "fix", "v2.0.0"},
+		{"1.2.3", false, "", ""},
+		{"pr-12", false, "", ""},
+		{"latest", false, "", ""},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.tag, func(t *testing.T) {
+			prefix, identifier, ok := SplitPrefix(test.tag)
+			require.Equal(t, test.expectedOk, ok)
+			require.Equal(t, test.expectedPrefix, prefix)
+			require.Equal(t, test.expectedVersion, identifier)
+		})
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	tags := []string{"master-1.0.0", "master-1.1.0", "master-1.2.0", "feature-a-0.1.0", "feature-a-0.2.0", "feature-b-0.1.0", "feature-b-0.1.1", "latest", "pr-12"}
+
+	t.Run("Keep applies to each prefix group", func(t *testing.T) {
+		selector := newTestSelector(t, "*", 1)
+		selector.GroupByPrefix = true
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"master-1.0.0", "master-1.1.0", "feature-a-0.1.0", "feature-b-0.1.0"}, selected)
+	})
+
+	t.Run("Stale groups are deleted entirely", func(t *testing.T) {
+		reference := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		selector := newTestSelector(t, "*", 1)
+		selector.Now = reference
+		selector.GroupByPrefix = true
+		selector.StaleAfter = 30 * 24 * time.Hour
+		selector.Created = map[string]time.Time{
+			"master-1.2.0":    reference.Add(-60 * 24 * time.Hour),
+			"feature-a-0.1.0": reference.Add(-90 * 24 * time.Hour),
+			"feature-a-0.2.0": reference.Add(-40 * 24 * time.Hour),
+			"feature-b-0.1.1": reference.Add(-1 * time.Hour),
+		}
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"master-1.0.0", "master-1.1.0", "master-1.2.0", "feature-a-0.1.0", "feature-a-0.2.0", "feature-b-0.1.0"}, selected)
+	})
+}
+
+func TestParseAge(t *testing.T) {
+	tdata := []struct {
+		age      string
+		expected time.Duration
+	}{
+		{"36h", 36 * time.Hour},
+		{"90d", 90 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+	}
+	for _, test := range tdata {
+		actual, err := ParseAge(test.age)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, actual)
+	}
+
+	_, err := ParseAge("ninety days")
+	require.Error(t, err)
+}
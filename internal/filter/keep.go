@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"sort"
+	"strings"
+)
+
+const (
+	KeepPerMajor = "major"
+	KeepPerMinor = "minor"
+)
+
+var KeepPerLines = []string{KeepPerMajor, KeepPerMinor}
+
+func CheckKeepPer(per string) error {
+	if per == "" || per == KeepPerMajor || per == KeepPerMinor {
+		return nil
+	}
+	return fmt.Errorf("unknown keep per \"%s\", use one of %s", per, strings.Join(KeepPerLines, ", "))
+}
+
+// dropNewest returns the sorted matches without the keep newest ones, a
+// negative keep keeps them all.
+func dropNewest(matches []Match, keep int) []Match {
+	return matches[:len(matches)-keptCount(keep, len(matches))]
+}
+
+// dropNewestPerLine groups semver matches by major, or major.minor, release
+// line and returns them without the keep newest of each line, in their
+// original order. Non semver matches are one more line ordered as given.
+func dropNewestPerLine(matches []Match, keep int, per string) []Match {
+	lines := make(map[string]version.Collection)
+	tags := make(map[*version.Version]string)
+	var others []Match
+
+	for _, m := range matches {
+		v, err := version.NewVersion(m.Version)
+		if err != nil {
+			others = append(others, m)
+			continue
+		}
+		segments := v.Segments()
+		line := fmt.Sprintf("%d", segments[0])
+		if per == KeepPerMinor {
+			line = fmt.Sprintf("%d.%d", segments[0], segments[1])
+		}
+		lines[line] = append(lines[line], v)
+		tags[v] = m.Tag
+	}
+
+	kept := make(map[string]bool)
+	for _, collection := range lines {
+		sort.Stable(collection)
+		for _, v := range collection[len(collection)-keptCount(keep, len(collection)):] {
+			kept[tags[v]] = true
+		}
+	}
+	for _, m := range others[len(others)-keptCount(keep, len(others)):] {
+		kept[m.Tag] = true
+	}
+
+	var dropped []Match
+	for _, m := range matches {
+		if !kept[m.Tag] {
+			dropped = append(dropped, m)
+		}
+	}
+	return dropped
+}
+
+// keptCount returns how many of total matches keep keeps, all of them when
+// keep is negative.
+func keptCount(keep, total int) int {
+	if keep < 0 || keep > total {
+		return total
+	}
+	return keep
+}
@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKeepPerLine(t *testing.T) {
+	tags := []string{"1.0.0", "1.0.1", "1.1.0", "1.1.1", "2.0.0", "2.0.1", "2.1.0", "2.2.0", "2.3.0", "nightly", "edge"}
+
+	tdata := []struct {
+		testCase string
+		keep     int
+		per      string
+		expected []string
+	}{
+		{
+			testCase: "Keep newest per major line",
+			keep:     1,
+			per:      KeepPerMajor,
+			expected: []string{"nightly", "1.0.0", "1.0.1", "1.1.0", "2.0.0", "2.0.1", "2.1.0", "2.2.0"},
+		},
+		{
+			testCase: "Keep two newest per minor line",
+			keep:     2,
+			per:      KeepPerMinor,
+			expected: []string(nil),
+		},
+		{
+			testCase: "Keep newest per minor line",
+			keep:     1,
+			per:      KeepPerMinor,
+			expected: []string{"nightly", "1.0.0", "1.1.0", "2.0.0"},
+		},
+		{
+			testCase: "Keep zero deletes every line",
+			keep:     0,
+			per:      KeepPerMajor,
+			expected: append([]string{"nightly", "edge"}, tags[:9]...),
+		},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			selector := newTestSelector(t, "*", test.keep)
+			selector.KeepPer = test.per
+			selected, _ := Select(tags, []Selector{selector}, nil)
+			require.Equal(t, test.expected, selected)
+		})
+	}
+
+	t.Run("Lines are sorted on semver whatever the registry order", func(t *testing.T) {
+		selector := newTestSelector(t, "v*", 1)
+		selector.KeepPer = KeepPerMajor
+		selected, _ := Select([]string{"v1.10.0", "v2.0.0", "v1.9.0"}, []Selector{selector}, nil)
+		require.Equal(t, []string{"v1.9.0"}, selected)
+	})
+
+	t.Run("Unknown keep per is rejected", func(t *testing.T) {
+		require.NoError(t, CheckKeepPer(KeepPerMinor))
+		require.Error(t, CheckKeepPer("patch"))
+	})
+}
@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const versionGroup = "version"
+
+// Pattern decides if a tag is a member of a selection and captures the
+// segment of the tag used as its version for sorting.
+type Pattern struct {
+	expression *regexp.Regexp
+}
+
+// NewGlobPattern compiles a glob where * matches any sequence of characters
+// and ? matches a single character. The version segment is the last group of
+// wildcards, wildcards only separated by dots belonging to the same group:
+// "*-master" captures "1.2.3" in "1.2.3-master", "release-?.*" captures
+// "1.4.2" in "release-1.4.2" and "feature-*-build-*" captures "12" in
+// "feature-login-build-12". A glob without wildcard only matches itself.
+func NewGlobPattern(glob string) (Pattern, error) {
+	if glob == "" {
+		return Pattern{}, errors.New("empty tag pattern")
+	}
+
+	tokens := tokenizeGlob(glob)
+	first, last := -1, -1
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if isWildcard(tokens[i]) {
+			if last == -1 {
+				last = i
+			}
+			first = i
+		} else if last != -1 && strings.Trim(tokens[i], ".") != "" {
+			break
+		}
+	}
+
+	var expression strings.Builder
+	expression.WriteString("^")
+	if last == -1 {
+		expression.WriteString("(?P<" + versionGroup + ">")
+	}
+	for i, token := range tokens {
+		if i == first {
+			expression.WriteString("(?P<" + versionGroup + ">")
+		}
+		switch token {
+		case "*":
+			expression.WriteString(".*")
+		case "?":
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(token))
+		}
+		if i == last {
+			expression.WriteString(")")
+		}
+	}
+	if last == -1 {
+		expression.WriteString(")")
+	}
+	expression.WriteString("$")
+
+	return Pattern{regexp.MustCompile(expression.String())}, nil
+}
+
+// NewRegexPattern compiles a full regular expression, it is not anchored.
+// The optional (?P<version>...) named group captures the version segment,
+// the whole match is used when it is missing.
+func NewRegexPattern(expression string) (Pattern, error) {
+	if expression == "" {
+		return Pattern{}, errors.New("empty tag regex")
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("invalid tag regex: %v", err)
+	}
+	return Pattern{compiled}, nil
+}
+
+// Match returns the version segment of tag and whether tag matches.
+func (p Pattern) Match(tag string) (string, bool) {
+	submatches := p.expression.FindStringSubmatch(tag)
+	if submatches == nil {
+		return "", false
+	}
+	index := p.expression.SubexpIndex(versionGroup)
+	if index == -1 {
+		return submatches[0], true
+	}
+	return submatches[index], true
+}
+
+func (p Pattern) String() string {
+	return p.expression.String()
+}
+
+// tokenizeGlob splits a glob in literal runs and single wildcards.
+func tokenizeGlob(glob string) []string {
+	var tokens []string
+	literal := ""
+	for _, char := range glob {
+		if char == '*' || char == '?' {
+			if literal != "" {
+				tokens = append(tokens, literal)
+				literal = ""
+			}
+			tokens = append(tokens, string(char))
+			continue
+		}
+		literal += string(char)
+	}
+	if literal != "" {
+		tokens = append(tokens, literal)
+	}
+	return tokens
+}
+
+func isWildcard(token string) bool {
+	return token == "*" || token == "?"
+}
+
+// GlobCovers tells if every tag matched by the inner glob is also matched by
+// the outer one, ie "*-master" covers "1.*-master". A * of inner can only be
+// covered by a * of outer.
+func GlobCovers(outer, inner string) bool {
+	o, i := []rune(outer), []rune(inner)
+	// memo[x][y] caches covers(o[x:], i[y:]), 0 unknown, 1 true, 2 false
+	memo := make([][]int, len(o)+1)
+	for x := range memo {
+		memo[x] = make([]int, len(i)+1)
+	}
+
+	var covers func(x, y int) bool
+	covers = func(x, y int) bool {
+		if memo[x][y] != 0 {
+			return memo[x][y] == 1
+		}
+		result := false
+		switch {
+		case x == len(o):
+			result = y == len(i)
+		case o[x] == '*':
+			result = covers(x+1, y) || (y < len(i) && covers(x, y+1))
+		case y == len(i) || i[y] == '*':
+			result = false
+		case o[x] == '?':
+			result = covers(x+1, y+1)
+		default:
+			result = o[x] == i[y] && covers(x+1, y+1)
+		}
+		memo[x][y] = 2
+		if result {
+			memo[x][y] = 1
+		}
+		return result
+	}
+	return covers(0, 0)
+}
@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGlobPattern(t *testing.T) {
+	tdata := []struct {
+		testCase        string
+		glob            string
+		tag             string
+		expectedMatch   bool
+		expectedVersion string
+	}{
+		{"Trailing wildcard captures suffix", "master-*", "master-1.0.0", true, "1.0.0"},
+		{"Trailing wildcard does not match other prefix", "master-*", "develop-1.0.0", false, ""},
+		{"Leading wildcard captures prefix", "*-master", "1.2.3-master", true, "1.2.3"},
+		{"Leading wildcard is anchored", "*-master", "1.2.3-master-old", false, ""},
+		{"Infix wildcard captures middle", "feature-*-build", "feature-login-build", true, "login"},
+		{"Last wildcard group is the version", "feature-*-build-*", "feature-login-build-12", true, "12"},
+		{"Dot separated wildcards are one group", "release-?.*", "release-1.4.2", true, "1.4.2"},
+		{"Question mark matches one character", "release-?.*", "release-10.4.2", false, ""},
+		{"Literal characters are not regex", "v1.0", "v1x0", false, ""},
+		{"No wildcard only matches itself", "master-1.0.1", "master-1.0.1", true, "master-1.0.1"},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			pattern, err := NewGlobPattern(test.glob)
+			require.NoError(t, err)
+
+			actual, ok := pattern.Match(test.tag)
+			require.Equal(t, test.expectedMatch, ok)
+			require.Equal(t, test.expectedVersion, actual)
+		})
+	}
+
+	t.Run("Empty glob is rejected", func(t *testing.T) {
+		_, err := NewGlobPattern("")
+		require.Error(t, err)
+	})
+}
+
+func TestRegexPattern(t *testing.T) {
+	tdata := []struct {
+		testCase        string
+		expression      string
+		tag             string
+		expectedMatch   bool
+		expectedVersion string
+	}{
+		{"Version group captures sorting segment", `^rc(?P<version>\d+\.\d+)-.*$`, "rc1.2-linux", true, "1.2"},
+		{"Membership is decided by the whole regex", `^rc(?P<version>\d+\.\d+)-.*$`, "rc1.2", false, ""},
+		{"Regex is not anchored", `master`, "old-master-1", true, "master"},
+		{"Whole match is the version without group", `\d+\.\d+\.\d+$`, "master-1.0.0", true, "1.0.0"},
+		{"Alternation is supported", `^(?:develop|main)-(?P<version>.+)$`, "main-2.0.0", true, "2.0.0"},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			pattern, err := NewRegexPattern(test.expression)
+			require.NoError(t, err)
+
+			actual, ok := pattern.Match(test.tag)
+			require.Equal(t, test.expectedMatch, ok)
+			require.Equal(t, test.expectedVersion, actual)
+		})
+	}
+
+	t.Run("Invalid regex is rejected", func(t *testing.T) {
+		_, err := NewRegexPattern("master-(")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid tag regex")
+	})
+}
+
+func TestGlobCovers(t *testing.T) {
+	tdata := []struct {
+		outer, inner string
+		expected     bool
+	}{
+		{"*", "master-*", true},
+		{"master-*", "master-1.*", true},
+		{"*-master", "1.?.*-master", true},
+		{"master-?", "master-1", true},
+		{"master-*", "*", false},
+		{"master-?", "master-*", false},
+		{"master-1", "master-?", false},
+		{"master-*", "develop-*", false},
+		{"latest", "latest", true},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.outer+" "+test.inner, func(t *testing.T) {
+			require.Equal(t, test.expected, GlobCovers(test.outer, test.inner))
+		})
+	}
+}
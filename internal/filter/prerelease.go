@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"github.com/hashicorp/go-version"
+	"sort"
+)
+
+// Prereleases selects semver pre-releases like 1.2.0-rc.1 on top of keep.
+// Final releases and non semver tags are never selected by it.
+type Prereleases struct {
+	// DeleteSuperseded selects pre-releases of a version whose final
+	// release is also matched, 1.2.0-rc.1 once 1.2.0 exists
+	DeleteSuperseded bool
+	// Limit enables Keep, the number of newest pre-releases to keep
+	Limit bool
+	Keep  int
+}
+
+func (p Prereleases) enabled() bool {
+	return p.DeleteSuperseded || p.Limit
+}
+
+// selectPrereleases returns the pre-release matches to delete, in the order
+// of matches.
+func (p Prereleases) selectPrereleases(matches []Match) []Match {
+	finals := make(map[string]bool)
+	var prereleases version.Collection
+	tags := make(map[*version.Version]Match)
+
+	for _, m := range matches {
+		v, err := version.NewVersion(m.Version)
+		if err != nil {
+			continue
+		}
+		if v.Prerelease() == "" {
+			finals[v.Core().String()] = true
+			continue
+		}
+		prereleases = append(prereleases, v)
+		tags[v] = m
+	}
+
+	selected := make(map[string]bool)
+	if p.DeleteSuperseded {
+		for _, v := range prereleases {
+			if finals[v.Core().String()] {
+				selected[tags[v].Tag] = true
+			}
+		}
+	}
+	if p.Limit {
+		sort.Stable(prereleases)
+		for _, v := range prereleases[:len(prereleases)-keptCount(p.Keep, len(prereleases))] {
+			selected[tags[v].Tag] = true
+		}
+	}
+
+	var result []Match
+	for _, m := range matches {
+		if selected[m.Tag] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPrereleases(t *testing.T) {
+	tags := []string{"1.1.0-rc.1", "1.1.0", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2", "1.2.0", "1.3.0-alpha", "1.3.0-rc.1", "nightly"}
+
+	tdata := []struct {
+		testCase    string
+		keep        int
+		prereleases Prereleases
+		expected    []string
+	}{
+		{
+			testCase:    "Delete pre-releases superseded by a final release",
+			keep:        100,
+			prereleases: Prereleases{DeleteSuperseded: true},
+			expected:    []string{"1.1.0-rc.1", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2"},
+		},
+		{
+			testCase:    "Keep only the newest pre-releases overall",
+			keep:        100,
+			prereleases: Prereleases{Limit: true, Keep: 2},
+			expected:    []string{"1.1.0-rc.1", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2"},
+		},
+		{
+			testCase:    "Both options combined",
+			keep:        100,
+			prereleases: Prereleases{DeleteSuperseded: true, Limit: true, Keep: 1},
+			expected:    []string{"1.1.0-rc.1", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2", "1.3.0-alpha"},
+		},
+		{
+			testCase:    "Limit to zero never selects final releases",
+			keep:        100,
+			prereleases: Prereleases{Limit: true, Keep: 0},
+			expected:    []string{"1.1.0-rc.1", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2", "1.3.0-alpha", "1.3.0-rc.1"},
+		},
+		{
+			testCase:    "Selected with keep once",
+			keep:        7,
+			prereleases: Prereleases{DeleteSuperseded: true},
+			expected:    []string{"nightly", "1.1.0-rc.1", "1.2.0-beta", "1.2.0-rc.1", "1.2.0-rc.2"},
+		},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			selector := newTestSelector(t, "*", test.keep)
+			selector.Prereleases = test.prereleases
+			selected, _ := Select(tags, []Selector{selector}, nil)
+			require.ElementsMatch(t, test.expected, selected)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package filter
+
+// Rule protects the tags matching its pattern from deletion.
+type Rule struct {
+	Name    string
+	Pattern Pattern
+}
+
+type Protected struct {
+	Tag  string `json:"tag"`
+	Rule string `json:"rule"`
+}
+
+// ReleaseRule is the name of the built-in rule protecting release tags.
+const ReleaseRule = "builtin:release"
+
+// DefaultProtectRules returns the built-in rules, applied on delete unless
+// turned off: latest, stable, prod-* and release tags like 1.2.3 or v1.2.3.
+func DefaultProtectRules() []Rule {
+	release, _ := NewRegexPattern(`^v?\d+\.\d+\.\d+$`)
+	rules := []Rule{{Name: ReleaseRule, Pattern: release}}
+	for _, glob := range []string{"latest", "stable", "prod-*"} {
+		pattern, _ := NewGlobPattern(glob)
+		rules = append(rules, Rule{Name: "builtin:" + glob, Pattern: pattern})
+	}
+	return rules
+}
+
+// NewExcludeRules compiles user given exclude globs.
+func NewExcludeRules(globs []string) ([]Rule, error) {
+	var rules []Rule
+	for _, glob := range globs {
+		pattern, err := NewGlobPattern(glob)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, Rule{Name: "exclude:" + glob, Pattern: pattern})
+	}
+	return rules, nil
+}
+
+// Protect removes the tags matched by any rule, keeping the order of the
+// remaining ones, and reports the first rule that protected each tag.
+func Protect(tags []string, rules []Rule) ([]string, []Protected) {
+	matches := make([]Match, len(tags))
+	for i, tag := range tags {
+		matches[i] = Match{Tag: tag}
+	}
+	remaining, protected := protectMatches(matches, rules)
+	var remainingTags []string
+	for _, m := range remaining {
+		remainingTags = append(remainingTags, m.Tag)
+	}
+	return remainingTags, protected
+}
+
+// protectMatches is Protect on matches, keeping their version.
+func protectMatches(matches []Match, rules []Rule) ([]Match, []Protected) {
+	var remaining []Match
+	var protected []Protected
+
+	for _, m := range matches {
+		rule, ok := matchRule(m.Tag, rules)
+		if ok {
+			protected = append(protected, Protected{m.Tag, rule})
+			continue
+		}
+		remaining = append(remaining, m)
+	}
+	return remaining, protected
+}
+
+func matchRule(tag string, rules []Rule) (string, bool) {
+	for _, rule := range rules {
+		if _, ok := rule.Pattern.Match(tag); ok {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
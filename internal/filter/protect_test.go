@@ -0,0 +1,37 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	tags := []string{"master-1.0.0", "latest", "1.2.3", "v2.0.0", "prod-eu", "stable", "keep-me", "master-1.0.1", "1.2.3-rc.1"}
+
+	t.Run("Built-in rules protect latest, stable, prod and releases", func(t *testing.T) {
+		remaining, protected := Protect(tags, DefaultProtectRules())
+
+		require.Equal(t, []string{"master-1.0.0", "keep-me", "master-1.0.1", "1.2.3-rc.1"}, remaining)
+		require.Equal(t, []Protected{
+			{"latest", "builtin:latest"},
+			{"1.2.3", "builtin:release"},
+			{"v2.0.0", "builtin:release"},
+			{"prod-eu", "builtin:prod-*"},
+			{"stable", "builtin:stable"},
+		}, protected)
+	})
+
+	t.Run("Exclude rules are reported with their glob", func(t *testing.T) {
+		rules, err := NewExcludeRules([]string{"keep-*", "*-1.0.1"})
+		require.NoError(t, err)
+
+		remaining, protected := Protect([]string{"keep-me", "master-1.0.0", "master-1.0.1"}, rules)
+		require.Equal(t, []string{"master-1.0.0"}, remaining)
+		require.Equal(t, []Protected{{"keep-me", "exclude:keep-*"}, {"master-1.0.1", "exclude:*-1.0.1"}}, protected)
+	})
+
+	t.Run("Empty exclude is rejected", func(t *testing.T) {
+		_, err := NewExcludeRules([]string{""})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"time"
+)
+
+// Selector matches tags with Pattern, and Constraints on their version when
+// set, orders them with Sorter and keeps the Keep newest matches, or the Keep
+// newest of each semver release line when KeepPer is major or minor. A
+// negative Keep keeps every match. Kept pre-releases can still be selected by
+// Prereleases.
+type Selector struct {
+	Pattern     Pattern
+	Constraints version.Constraints
+	Keep        int
+	KeepPer     string
+	Sorter      Sorter
+	Prereleases Prereleases
+	// GroupByPrefix splits matches in <prefix>-<semver> and applies the
+	// selection to each prefix group, a group whose newest tag was
+	// created more than StaleAfter before Now, the current time when zero,
+	// is dropped entirely
+	GroupByPrefix bool
+	StaleAfter    time.Duration
+	Created       map[string]time.Time
+	Now           time.Time
+	// Git selects the matches of deleted branches or unreachable commits
+	Git *GitRefs
+	// Where, when set, is a condition every match must also satisfy
+	Where func(m Match) bool
+}
+
+// NewConstraints parses semver constraints like "<2.0.0" or ">=1.0, <1.5".
+func NewConstraints(constraints string) (version.Constraints, error) {
+	parsed, err := version.NewConstraint(constraints)
+	if err != nil {
+		return nil, fmt.Errorf("invalid semver constraint: %v", err)
+	}
+	return parsed, nil
+}
+
+// Match tells if tag is a member of the selection. Tags whose version is not
+// semver never match a selector with constraints, tags must satisfy Where
+// when set.
+func (s Selector) Match(tag string) (Match, bool) {
+	identifier, ok := s.Pattern.Match(tag)
+	if !ok {
+		return Match{}, false
+	}
+	if s.Constraints != nil {
+		v, err := version.NewVersion(identifier)
+		if err != nil || !s.Constraints.Check(v) {
+			return Match{}, false
+		}
+	}
+	if s.Where != nil && !s.Where(Match{tag, identifier}) {
+		return Match{}, false
+	}
+	return Match{tag, identifier}, true
+}
+
+func (s Selector) matchTags(tags []string) []Match {
+	var matches []Match
+	for _, tag := range tags {
+		if m, ok := s.Match(tag); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// Select evaluates each selector independently against tags and returns the
+// union of the tags to delete, in selector order, and the protected ones.
+// Protected tags are removed before keep is applied so they don't count
+// toward it. All unprotected tags are selected when there is no selector.
+func Select(tags []string, selectors []Selector, rules []Rule) ([]string, []Protected) {
+	if len(selectors) == 0 {
+		return Protect(tags, rules)
+	}
+
+	var selected []string
+	var protected []Protected
+	seen := make(map[string]bool)
+	seenProtected := make(map[string]bool)
+
+	for _, selector := range selectors {
+		matches, matchedProtected := protectMatches(selector.matchTags(tags), rules)
+		dropped := selector.drop(matches)
+		if selector.GroupByPrefix {
+			dropped = selector.dropGroups(matches)
+		}
+		if selector.Git != nil {
+			dropped = append(dropped, selector.Git.selectGone(matches)...)
+		}
+		for _, m := range dropped {
+			if !seen[m.Tag] {
+				seen[m.Tag] = true
+				selected = append(selected, m.Tag)
+			}
+		}
+		for _, p := range matchedProtected {
+			if !seenProtected[p.Tag] {
+				seenProtected[p.Tag] = true
+				protected = append(protected, p)
+			}
+		}
+	}
+	return selected, protected
+}
+
+// drop sorts matches and returns the ones not kept, never the ones the git
+// strategies can't order.
+func (s Selector) drop(matches []Match) []Match {
+	if s.Sorter.keepsUnorderable() {
+		matches = s.Sorter.orderable(matches)
+	}
+	matches = s.Sorter.Sort(matches)
+	dropped := dropNewest(matches, s.Keep)
+	if s.KeepPer != "" {
+		dropped = dropNewestPerLine(matches, s.Keep, s.KeepPer)
+	}
+	if s.Prereleases.enabled() {
+		dropped = append(dropped, s.Prereleases.selectPrereleases(matches)...)
+	}
+	return dropped
+}
+
+// SelectorTags returns the tags matched by at least one selector, all tags
+// when there is no selector.
+func SelectorTags(tags []string, selectors []Selector) []string {
+	if len(selectors) == 0 {
+		return tags
+	}
+	var matched []string
+	for _, tag := range tags {
+		for _, selector := range selectors {
+			if _, ok := selector.Match(tag); ok {
+				matched = append(matched, tag)
+				break
+			}
+		}
+	}
+	return matched
+}
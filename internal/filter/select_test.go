@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestSelector(t *testing.T, glob string, keep int) Selector {
+	pattern, err := NewGlobPattern(glob)
+	require.NoError(t, err)
+	return Selector{Pattern: pattern, Keep: keep}
+}
+
+func TestSelect(t *testing.T) {
+	tags := []string{"latest", "master-1.0.1", "develop-2.0.0", "master-0.9.2", "pr-12", "master-1.0.0", "develop-2.1.0", "pr-13"}
+
+	t.Run("Select all unprotected tags without selector", func(t *testing.T) {
+		selected, protected := Select(tags, nil, DefaultProtectRules())
+		require.Equal(t, []string{"master-1.0.1", "develop-2.0.0", "master-0.9.2", "pr-12", "master-1.0.0", "develop-2.1.0", "pr-13"}, selected)
+		require.Equal(t, []Protected{{"latest", "builtin:latest"}}, protected)
+	})
+
+	t.Run("Each selector keeps its own newest tags", func(t *testing.T) {
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 1),
+			newTestSelector(t, "develop-*", 0),
+			newTestSelector(t, "pr-*", 1),
+		}
+		selected, protected := Select(tags, selectors, nil)
+		require.Equal(t, []string{"master-0.9.2", "master-1.0.0", "develop-2.0.0", "develop-2.1.0", "pr-12"}, selected)
+		require.Empty(t, protected)
+	})
+
+	t.Run("Overlapping selectors are deleted once", func(t *testing.T) {
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 2),
+			newTestSelector(t, "*-0.9.2", 0),
+		}
+		selected, _ := Select(tags, selectors, nil)
+		require.Equal(t, []string{"master-0.9.2"}, selected)
+	})
+
+	t.Run("Protected tags do not count toward keep and are reported once", func(t *testing.T) {
+		rules, err := NewExcludeRules([]string{"master-1.0.1"})
+		require.NoError(t, err)
+		selectors := []Selector{
+			newTestSelector(t, "master-*", 1),
+			newTestSelector(t, "*-1.0.1", 0),
+		}
+		selected, protected := Select(tags, selectors, rules)
+		require.Equal(t, []string{"master-0.9.2"}, selected)
+		require.Equal(t, []Protected{{"master-1.0.1", "exclude:master-1.0.1"}}, protected)
+	})
+
+	t.Run("Where condition applies before keep", func(t *testing.T) {
+		selector := newTestSelector(t, "master-*", 1)
+		selector.Where = func(m Match) bool {
+			return m.Version != "1.0.1"
+		}
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"master-0.9.2"}, selected)
+	})
+}
+
+func TestSelectorTags(t *testing.T) {
+	tags := []string{"latest", "master-1.0.1", "develop-2.0.0", "pr-12"}
+
+	require.Equal(t, tags, SelectorTags(tags, nil))
+	require.Equal(t, []string{"master-1.0.1", "pr-12"}, SelectorTags(tags, []Selector{newTestSelector(t, "pr-*", 0), newTestSelector(t, "master-*", 0)}))
+}
+
+func TestSelectConstraints(t *testing.T) {
+	tags := []string{"latest", "0.9.0", "1.0.0", "1.4.1", "1.4.2", "1.5.0", "2.0.0", "v2.1.0", "master-1.2.0", "master-2.2.0"}
+
+	tdata := []struct {
+		testCase    string
+		glob        string
+		constraints string
+		expected    []string
+	}{
+		{"Less than", "*", "<2.0.0", []string{"0.9.0", "1.0.0", "1.4.1", "1.4.2", "1.5.0"}},
+		{"Range", "*", ">=1.0, <1.5", []string{"1.0.0", "1.4.1", "1.4.2"}},
+		{"Not equal", "*", "!=1.4.2", []string{"0.9.0", "1.0.0", "1.4.1", "1.5.0", "2.0.0", "v2.1.0"}},
+		{"Constraint on version segment", "master-*", ">=2", []string{"master-2.2.0"}},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			constraints, err := NewConstraints(test.constraints)
+			require.NoError(t, err)
+			selector := newTestSelector(t, test.glob, 0)
+			selector.Constraints = constraints
+
+			selected, _ := Select(tags, []Selector{selector}, nil)
+			require.Equal(t, test.expected, selected)
+			require.Equal(t, test.expected, SelectorTags(tags, []Selector{selector}))
+		})
+	}
+
+	t.Run("Invalid constraint is rejected", func(t *testing.T) {
+		_, err := NewConstraints("<<2")
+		require.Error(t, err)
+	})
+}
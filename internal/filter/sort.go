@@ -0,0 +1,233 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-version"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortSemver    = "semver"
+	SortLexical   = "lexical"
+	SortNatural   = "natural"
+	SortCalver    = "calver"
+	SortTimestamp = "timestamp"
+	SortCreated   = "created"
+	SortGitTime   = "git-time"
+	SortGitTopo   = "git-topo"
+)
+
+var SortStrategies = []string{SortSemver, SortLexical, SortNatural, SortCalver, SortTimestamp, SortCreated, SortGitTime, SortGitTopo}
+
+// SortData holds what the created and git strategies order on.
+type SortData struct {
+	// Created is the image creation time of each tag
+	Created map[string]time.Time
+	// History resolves the commit SHA embedded in versions
+	History GitHistory
+}
+
+// Match is a tag matched by a Pattern with its captured version segment.
+type Match struct {
+	Tag     string
+	Version string
+}
+
+// Sorter orders matches from oldest to newest. The zero value sorts on
+// semver.
+type Sorter struct {
+	Name string
+	Data SortData
+	// key returns a value comparable with less, false if the match can't
+	// be ordered by this strategy
+	key  func(m Match) (interface{}, bool)
+	less func(a, b interface{}) bool
+}
+
+// NewSorter returns the named strategy. Sorting on created uses the image
+// creation time of each tag, sorting on git the commit time or topological
+// order of the SHA in versions. Tags missing from data can't be ordered.
+func NewSorter(name string, data SortData) (Sorter, error) {
+	switch name {
+	case SortSemver, "":
+		return Sorter{}, nil
+	case SortLexical:
+		return Sorter{name, data, lexicalKey, lexicalLess}, nil
+	case SortNatural:
+		return Sorter{name, data, naturalKey, naturalLess}, nil
+	case SortCalver:
+		return Sorter{name, data, calverKey, naturalLess}, nil
+	case SortTimestamp:
+		return Sorter{name, data, timestampKey, timeLess}, nil
+	case SortCreated:
+		return Sorter{name, data, createdKey(data.Created), timeLess}, nil
+	case SortGitTime:
+		return Sorter{name, data, commitTimeKey(data.History), timeLess}, nil
+	case SortGitTopo:
+		return Sorter{name, data, commitOrderKey(data.History), orderLess}, nil
+	}
+	return Sorter{}, fmt.Errorf("unknown sort strategy \"%s\", use one of %s", name, strings.Join(SortStrategies, ", "))
+}
+
+// keepsUnorderable tells if the matches the strategy can't order are never
+// deleted: commits missing from the git history are usually the newest ones,
+// not fetched yet.
+func (s Sorter) keepsUnorderable() bool {
+	return s.Name == SortGitTime || s.Name == SortGitTopo
+}
+
+// orderable returns the matches the strategy can order, in their order.
+func (s Sorter) orderable(matches []Match) []Match {
+	if s.key == nil {
+		return matches
+	}
+	var orderable []Match
+	for _, m := range matches {
+		if _, ok := s.key(m); ok {
+			orderable = append(orderable, m)
+		}
+	}
+	return orderable
+}
+
+// Sort returns non orderable matches first, in their original order, then
+// the orderable ones from oldest to newest.
+func (s Sorter) Sort(matches []Match) []Match {
+	if s.key == nil {
+		s = Sorter{SortSemver, s.Data, semverKey, semverLess}
+	}
+
+	var sorted []Match
+	var orderable []Match
+	keys := make(map[string]interface{})
+	for _, m := range matches {
+		key, ok := s.key(m)
+		if !ok {
+			sorted = append(sorted, m)
+			continue
+		}
+		keys[m.Tag] = key
+		orderable = append(orderable, m)
+	}
+
+	sort.SliceStable(orderable, func(i, j int) bool {
+		return s.less(keys[orderable[i].Tag], keys[orderable[j].Tag])
+	})
+	return append(sorted, orderable...)
+}
+
+func semverKey(m Match) (interface{}, bool) {
+	v, err := version.NewVersion(m.Version)
+	return v, err == nil
+}
+
+func semverLess(a, b interface{}) bool {
+	return a.(*version.Version).LessThan(b.(*version.Version))
+}
+
+func lexicalKey(m Match) (interface{}, bool) {
+	return m.Version, true
+}
+
+func lexicalLess(a, b interface{}) bool {
+	return a.(string) < b.(string)
+}
+
+var chunksRegexp = regexp.MustCompile(`\d+|\D+`)
+
+var numbersRegexp = regexp.MustCompile(`\d+`)
+
+// naturalKey splits the version in digit and non digit chunks so that
+// build-9 comes before build-123.
+func naturalKey(m Match) (interface{}, bool) {
+	return chunksRegexp.FindAllString(m.Version, -1), true
+}
+
+func naturalLess(a, b interface{}) bool {
+	chunksA, chunksB := a.([]string), b.([]string)
+	for i := 0; i < len(chunksA) && i < len(chunksB); i++ {
+		if chunksA[i] == chunksB[i] {
+			continue
+		}
+		numberA, errA := strconv.ParseUint(chunksA[i], 10, 64)
+		numberB, errB := strconv.ParseUint(chunksB[i], 10, 64)
+		if errA == nil && errB == nil && numberA != numberB {
+			return numberA < numberB
+		}
+		return chunksA[i] < chunksB[i]
+	}
+	return len(chunksA) < len(chunksB)
+}
+
+var calverRegexp = regexp.MustCompile(`^v?(\d{4}|\d{2})[.\-_](\d{1,2})([.\-_]\d+)*$`)
+
+// calverKey accepts YYYY.MM, YY.MM and following numeric parts like
+// 2024.01.31 or 24.1.3, with dots, dashes or underscores.
+func calverKey(m Match) (interface{}, bool) {
+	submatches := calverRegexp.FindStringSubmatch(m.Version)
+	if submatches == nil {
+		return nil, false
+	}
+	month, _ := strconv.Atoi(submatches[2])
+	if month < 1 || month > 12 {
+		return nil, false
+	}
+	chunks := numbersRegexp.FindAllString(m.Version, -1)
+	if len(submatches[1]) == 2 {
+		chunks[0] = "20" + chunks[0]
+	}
+	return chunks, true
+}
+
+var timestampRegexp = regexp.MustCompile(`(\d{8})(?:[\-_T]?(\d{4}(?:\d{2})?))?`)
+
+// timestampKey finds an embedded timestamp like 20240131, 20240131-1530 or
+// 20240131T153000 anywhere in the version.
+func timestampKey(m Match) (interface{}, bool) {
+	for _, submatches := range timestampRegexp.FindAllStringSubmatch(m.Version, -1) {
+		layout, value := "20060102", submatches[1]
+		switch len(submatches[2]) {
+		case 4:
+			layout, value = layout+"1504", value+submatches[2]
+		case 6:
+			layout, value = layout+"150405", value+submatches[2]
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func createdKey(created map[string]time.Time) func(m Match) (interface{}, bool) {
+	return func(m Match) (interface{}, bool) {
+		t, ok := created[m.Tag]
+		return t, ok
+	}
+}
+
+func timeLess(a, b interface{}) bool {
+	return a.(time.Time).Before(b.(time.Time))
+}
+
+func commitTimeKey(history GitHistory) func(m Match) (interface{}, bool) {
+	return func(m Match) (interface{}, bool) {
+		commit, ok := history.Resolve(m.Version)
+		return commit.Time, ok
+	}
+}
+
+func commitOrderKey(history GitHistory) func(m Match) (interface{}, bool) {
+	return func(m Match) (interface{}, bool) {
+		commit, ok := history.Resolve(m.Version)
+		return commit.Order, ok
+	}
+}
+
+func orderLess(a, b interface{}) bool {
+	return a.(int) < b.(int)
+}
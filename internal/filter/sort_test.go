@@ -0,0 +1,128 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestMatches(versions ...string) []Match {
+	var matches []Match
+	for _, v := range versions {
+		matches = append(matches, Match{"tag-" + v, v})
+	}
+	return matches
+}
+
+func matchVersions(matches []Match) []string {
+	var versions []string
+	for _, m := range matches {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func TestSorter(t *testing.T) {
+	tdata := []struct {
+		testCase string
+		strategy string
+		versions []string
+		expected []string
+	}{
+		{
+			testCase: "Semver puts non semver first then oldest to newest",
+			strategy: SortSemver,
+			versions: []string{"1.10.0", "abc", "1.9.0", "1.0", "2.0.0-rc.1"},
+			expected: []string{"abc", "1.0", "1.9.0", "1.10.0", "2.0.0-rc.1"},
+		},
+		{
+			testCase: "Lexical sorts strings",
+			strategy: SortLexical,
+			versions: []string{"b", "a10", "a9"},
+			expected: []string{"a10", "a9", "b"},
+		},
+		{
+			testCase: "Natural sorts embedded numbers numerically",
+			strategy: SortNatural,
+			versions: []string{"build-123", "build-9", "build-45", "alpha"},
+			expected: []string{"alpha", "build-9", "build-45", "build-123"},
+		},
+		{
+			testCase: "Calver sorts dates and puts others first",
+			strategy: SortCalver,
+			versions: []string{"2024.01.31", "23.12.1", "2024.1.5", "2024.13", "latest", "2023.12.2"},
+			expected: []string{"2024.13", "latest", "23.12.1", "2023.12.2", "2024.01.31", "2024.1.5"},
+		},
+		{
+			testCase: "Timestamp sorts embedded timestamps",
+			strategy: SortTimestamp,
+			versions: []string{"main-20240131-1530", "main-20240131-0900", "main-20231231", "main-abc", "20240201T000001"},
+			expected: []string{"main-abc", "main-20231231", "main-20240131-0900", "main-20240131-1530", "20240201T000001"},
+		},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			sorter, err := NewSorter(test.strategy, SortData{})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, matchVersions(sorter.Sort(newTestMatches(test.versions...))))
+		})
+	}
+
+	t.Run("Created sorts on image creation time and puts unknown first", func(t *testing.T) {
+		now := time.Now()
+		sorter, err := NewSorter(SortCreated, SortData{Created: map[string]time.Time{
+			"tag-a": now,
+			"tag-b": now.Add(-time.Hour),
+			"tag-c": now.Add(time.Hour),
+		}})
+		require.NoError(t, err)
+		require.Equal(t, []string{"d", "b", "a", "c"}, matchVersions(sorter.Sort(newTestMatches("a", "b", "c", "d"))))
+	})
+
+	t.Run("Git sorts on commit time or topological order and puts unknown first", func(t *testing.T) {
+		reference := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		history := GitHistory{
+			"aaaaaaa": {{"aaaaaaa100", reference, 1}},
+			"bbbbbbb": {{"bbbbbbb100", reference.Add(-time.Hour), 3}},
+			"ccccccc": {{"ccccccc100", reference.Add(time.Hour), 2}},
+		}
+		matches := newTestMatches("main-bbbbbbb", "main-aaaaaaa", "main-ddddddd", "main-ccccccc", "main-12")
+
+		sorter, err := NewSorter(SortGitTime, SortData{History: history})
+		require.NoError(t, err)
+		require.Equal(t, []string{"main-ddddddd", "main-12", "main-bbbbbbb", "main-aaaaaaa", "main-ccccccc"}, matchVersions(sorter.Sort(matches)))
+
+		sorter, err = NewSorter(SortGitTopo, SortData{History: history})
+		require.NoError(t, err)
+		require.Equal(t, []string{"main-ddddddd", "main-12", "main-aaaaaaa", "main-ccccccc", "main-bbbbbbb"}, matchVersions(sorter.Sort(matches)))
+	})
+
+	t.Run("Git strategies never delete tags of unknown commits", func(t *testing.T) {
+		history := GitHistory{
+			"aaaaaaa": {{"aaaaaaa100", time.Now(), 1}},
+			"ccccccc": {{"ccccccc100", time.Now(), 2}},
+		}
+		sorter, err := NewSorter(SortGitTopo, SortData{History: history})
+		require.NoError(t, err)
+		pattern, err := NewGlobPattern("main-*")
+		require.NoError(t, err)
+		selector := Selector{Pattern: pattern, Sorter: sorter, Keep: 1}
+		tags := []string{"main-aaaaaaa", "main-ddddddd", "main-ccccccc"}
+
+		selected, _ := Select(tags, []Selector{selector}, nil)
+		require.Equal(t, []string{"main-aaaaaaa"}, selected)
+		decisions := Explain(tags, []Selector{selector}, nil)
+		require.Equal(t, ReasonUnordered, decisions[1].Reason)
+		require.False(t, decisions[1].Delete)
+	})
+
+	t.Run("Zero value sorts on semver", func(t *testing.T) {
+		require.Equal(t, []string{"1.0.0", "1.0.1"}, matchVersions(Sorter{}.Sort(newTestMatches("1.0.1", "1.0.0"))))
+	})
+
+	t.Run("Unknown strategy is rejected", func(t *testing.T) {
+		_, err := NewSorter("random", SortData{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/r0mdau/go-clean-docker-registry/internal/filter"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadRepository lists the local and remote branches of the repository at
+// dir, the commits reachable from them and all the commits it holds.
+func ReadRepository(dir string) (filter.GitRefs, error) {
+	output, err := run(dir, "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads", "refs/remotes")
+	if err != nil {
+		return filter.GitRefs{}, err
+	}
+	refs, err := parseRefs(bytes.NewReader(output))
+	if err != nil {
+		return filter.GitRefs{}, err
+	}
+
+	output, err = run(dir, "rev-list", "--branches", "--remotes")
+	if err != nil {
+		return filter.GitRefs{}, err
+	}
+	refs.Commits = indexCommits(strings.Fields(string(output)))
+
+	output, err = run(dir, "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype)")
+	if err != nil {
+		return filter.GitRefs{}, err
+	}
+	var known []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[1] == "commit" {
+			known = append(known, fields[0])
+		}
+	}
+	refs.Known = indexCommits(known)
+	return refs, nil
+}
+
+// indexCommits indexes full SHAs by their 7 first characters.
+func indexCommits(commits []string) map[string][]string {
+	index := make(map[string][]string)
+	for _, commit := range commits {
+		if len(commit) >= 7 {
+			index[commit[:7]] = append(index[commit[:7]], commit)
+		}
+	}
+	return index
+}
+
+// ReadHistory returns the commits reachable from local and remote branches
+// with their commit time and topological order.
+func ReadHistory(dir string) (filter.GitHistory, error) {
+	output, err := run(dir, "log", "--branches", "--remotes", "--topo-order", "--format=%H %ct")
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	history := make(filter.GitHistory)
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) < 7 {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("git log: invalid commit time %s", line)
+		}
+		// git log lists the newest commits first
+		commit := filter.Commit{SHA: fields[0], Time: time.Unix(seconds, 0), Order: len(lines) - i}
+		history[fields[0][:7]] = append(history[fields[0][:7]], commit)
+	}
+	return history, nil
+}
+
+// ReadRefsFile reads the output of git for-each-ref saved in a file. Only
+// branches are known this way, not the commits reachable from them.
+func ReadRefsFile(path string) (filter.GitRefs, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return filter.GitRefs{}, err
+	}
+	defer file.Close()
+	return parseRefs(file)
+}
+
+// parseRefs reads lines holding a refs/heads/ or refs/remotes/<remote>/ ref
+// name, like "<sha> commit\trefs/heads/main" of the default for-each-ref
+// format. No branch at all is an error to never delete everything because
+// of an empty or wrong file.
+func parseRefs(reader io.Reader) (filter.GitRefs, error) {
+	refs := filter.GitRefs{Branches: make(map[string]bool)}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			branch, ok := branchName(field)
+			if ok {
+				refs.Branches[filter.BranchPrefix(branch)] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return refs, err
+	}
+	if len(refs.Branches) == 0 {
+		return refs, errors.New("no git branch found in refs")
+	}
+	return refs, nil
+}
+
+func branchName(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "refs/heads/") {
+		return strings.TrimPrefix(ref, "refs/heads/"), true
+	}
+	if strings.HasPrefix(ref, "refs/remotes/") {
+		parts := strings.SplitN(strings.TrimPrefix(ref, "refs/remotes/"), "/", 2)
+		if len(parts) == 2 && parts[1] != "HEAD" {
+			return parts[1], true
+		}
+	}
+	return "", false
+}
+
+func run(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	command := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,103 @@
+package git
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRefs(t *testing.T) {
+	t.Run("Default for-each-ref format with local and remote branches", func(t *testing.T) {
+		output := "3f2a9c1b0000000000000000000000000000000 commit\trefs/heads/main\n" +
+			"aaaaaaa1000000000000000000000000000000 commit\trefs/remotes/origin/feature/login\n" +
+			"aaaaaaa1000000000000000000000000000000 commit\trefs/remotes/origin/HEAD\n" +
+			"bbbbbbb1000000000000000000000000000000 tag\trefs/tags/v1.0.0\n"
+
+		refs, err := parseRefs(strings.NewReader(output))
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"main": true, "feature-login": true}, refs.Branches)
+		require.Nil(t, refs.Commits)
+	})
+
+	t.Run("No branch is an error", func(t *testing.T) {
+		_, err := parseRefs(strings.NewReader("\n"))
+		require.Error(t, err)
+	})
+
+	t.Run("Missing refs file is an error", func(t *testing.T) {
+		_, err := ReadRefsFile(filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+	})
+}
+
+// newTestRepository creates a repository with a main branch of two commits
+// and a deleted feature branch, or skips the test when git is missing.
+func newTestRepository(t *testing.T) (string, []string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	date := ""
+	git := func(args ...string) string {
+		command := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		output, err := command.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+	commit := func(message, commitDate string) string {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file"), []byte(message), 0644))
+		date = commitDate
+		git("add", "file")
+		git("commit", "-q", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "-q")
+	git("checkout", "-q", "-b", "main")
+	first := commit("first", "2024-01-01T10:00:00Z")
+	second := commit("second", "2024-01-02T10:00:00Z")
+	git("checkout", "-q", "-b", "feature/gone")
+	gone := commit("gone", "2024-01-03T10:00:00Z")
+	git("checkout", "-q", "main")
+	git("branch", "-q", "-D", "feature/gone")
+	return dir, []string{first, second, gone}
+}
+
+func TestReadRepository(t *testing.T) {
+	dir, commits := newTestRepository(t)
+
+	refs, err := ReadRepository(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"main": true}, refs.Branches)
+	require.True(t, refs.Reachable(commits[0][:7]))
+	require.True(t, refs.Reachable(commits[1]))
+	require.False(t, refs.Reachable(commits[2][:7]))
+	require.Len(t, refs.Known, 3)
+
+	_, err = ReadRepository(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
+
+func TestReadHistory(t *testing.T) {
+	dir, commits := newTestRepository(t)
+
+	history, err := ReadHistory(dir)
+	require.NoError(t, err)
+
+	first, ok := history.Resolve("main-" + commits[0][:7])
+	require.True(t, ok)
+	second, ok := history.Resolve(commits[1][:10])
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), first.Time.UTC())
+	require.True(t, first.Order < second.Order)
+
+	_, ok = history.Resolve(commits[2][:7])
+	require.False(t, ok)
+}
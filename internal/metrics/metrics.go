@@ -0,0 +1,186 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds in seconds of latency histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Set holds metric families, safe for concurrent use.
+type Set struct {
+	mutex    sync.Mutex
+	families []*Family
+}
+
+func NewSet() *Set {
+	return &Set{}
+}
+
+// Family is a metric with its label names, one series per label values.
+type Family struct {
+	set     *Set
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+func (s *Set) add(name, help, kind string, buckets []float64, labels []string) *Family {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	family := &Family{s, name, help, kind, labels, buckets, make(map[string]*series)}
+	s.families = append(s.families, family)
+	return family
+}
+
+// Counter adds a family of values that only go up.
+func (s *Set) Counter(name, help string, labels ...string) *Family {
+	return s.add(name, help, kindCounter, nil, labels)
+}
+
+// Gauge adds a family of values that are set.
+func (s *Set) Gauge(name, help string, labels ...string) *Family {
+	return s.add(name, help, kindGauge, nil, labels)
+}
+
+// Histogram adds a family of observations counted in buckets.
+func (s *Set) Histogram(name, help string, buckets []float64, labels ...string) *Family {
+	return s.add(name, help, kindHistogram, buckets, labels)
+}
+
+// get returns the series of the label values, the lock being held.
+func (f *Family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...), counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add increases the value of a counter or gauge.
+func (f *Family) Add(value float64, labels ...string) {
+	f.set.mutex.Lock()
+	defer f.set.mutex.Unlock()
+	f.get(labels).value += value
+}
+
+// Set replaces the value of a gauge.
+func (f *Family) Set(value float64, labels ...string) {
+	f.set.mutex.Lock()
+	defer f.set.mutex.Unlock()
+	f.get(labels).value = value
+}
+
+// Observe counts a value in a histogram.
+func (f *Family) Observe(value float64, labels ...string) {
+	f.set.mutex.Lock()
+	defer f.set.mutex.Unlock()
+	s := f.get(labels)
+	for i, bound := range f.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// Value returns the value of a counter or gauge, the sum of a histogram.
+func (f *Family) Value(labels ...string) float64 {
+	f.set.mutex.Lock()
+	defer f.set.mutex.Unlock()
+	return f.get(labels).value
+}
+
+// WriteTo writes every family in the Prometheus text format, series sorted
+// by label values.
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var b strings.Builder
+	for _, f := range s.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := f.series[key]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labelPairs(f.labels, series.labels, "", ""), formatValue(series.value))
+				continue
+			}
+			for i, bound := range f.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, labelPairs(f.labels, series.labels, "le", formatValue(bound)), series.counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, labelPairs(f.labels, series.labels, "le", "+Inf"), series.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, labelPairs(f.labels, series.labels, "", ""), formatValue(series.value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", f.name, labelPairs(f.labels, series.labels, "", ""), series.count)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the families on any path, mount it on /metrics.
+func (s *Set) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.WriteTo(w)
+}
+
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+"=\""+escape(values[i])+"\"")
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+"=\""+extraValue+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
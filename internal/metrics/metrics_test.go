@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	set := NewSet()
+	deleted := set.Counter("tags_deleted_total", "Tags deleted", "repository")
+	last := set.Gauge("last_success_timestamp_seconds", "Last successful run")
+	latency := set.Histogram("request_duration_seconds", "Request latency", []float64{0.1, 1}, "endpoint", "status")
+
+	deleted.Add(2, "r0mdau/nodejs")
+	deleted.Add(1, "r0mdau/nodejs")
+	deleted.Add(1, `a"b`)
+	last.Set(1615802400)
+	latency.Observe(0.05, "manifests", "202")
+	latency.Observe(0.5, "manifests", "202")
+	require.Equal(t, float64(3), deleted.Value("r0mdau/nodejs"))
+
+	recorder := httptest.NewRecorder()
+	set.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, strings.Join([]string{
+		"# HELP tags_deleted_total Tags deleted",
+		"# TYPE tags_deleted_total counter",
+		`tags_deleted_total{repository="a\"b"} 1`,
+		`tags_deleted_total{repository="r0mdau/nodejs"} 3`,
+		"# HELP last_success_timestamp_seconds Last successful run",
+		"# TYPE last_success_timestamp_seconds gauge",
+		"last_success_timestamp_seconds 1.6158024e+09",
+		"# HELP request_duration_seconds Request latency",
+		"# TYPE request_duration_seconds histogram",
+		`request_duration_seconds_bucket{endpoint="manifests",status="202",le="0.1"} 1`,
+		`request_duration_seconds_bucket{endpoint="manifests",status="202",le="1"} 2`,
+		`request_duration_seconds_bucket{endpoint="manifests",status="202",le="+Inf"} 2`,
+		`request_duration_seconds_sum{endpoint="manifests",status="202"} 0.55`,
+		`request_duration_seconds_count{endpoint="manifests",status="202"} 2`,
+		"",
+	}, "\n"), recorder.Body.String())
+
+	require.Panics(t, func() { deleted.Add(1) })
+}
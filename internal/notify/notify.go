@@ -0,0 +1,178 @@
+// Package notify posts the summary of cleanup runs to webhooks, as generic
+// JSON or as Slack messages, optionally shaped by a text/template.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+)
+
+var Formats = []string{FormatGeneric, FormatSlack}
+
+// Summary is what a run did, the data of templates.
+type Summary struct {
+	Command        string         `json:"command"`
+	Host           string         `json:"host"`
+	Start          time.Time      `json:"start"`
+	End            time.Time      `json:"end"`
+	Images         []ImageSummary `json:"images"`
+	Deleted        int            `json:"deleted"`
+	Skipped        int            `json:"skipped"`
+	Failed         int            `json:"failed"`
+	BytesReclaimed int64          `json:"bytes_reclaimed"`
+	Errors         []string       `json:"errors"`
+}
+
+// ImageSummary is what a run did in one image, skipped tags being the
+// protected ones and those left when the maintenance window ended.
+type ImageSummary struct {
+	Image          string `json:"image"`
+	Deleted        int    `json:"deleted"`
+	Skipped        int    `json:"skipped"`
+	Failed         int    `json:"failed"`
+	BytesReclaimed int64  `json:"bytes_reclaimed"`
+}
+
+// Add counts image in the totals of the summary.
+func (s *Summary) Add(image ImageSummary, errors ...string) {
+	s.Images = append(s.Images, image)
+	s.Deleted += image.Deleted
+	s.Skipped += image.Skipped
+	s.Failed += image.Failed
+	s.BytesReclaimed += image.BytesReclaimed
+	s.Errors = append(s.Errors, errors...)
+}
+
+// Webhook is where and how to post summaries. Template renders the whole
+// body of generic webhooks and the text of Slack messages.
+type Webhook struct {
+	URL      string
+	Format   string
+	Template string
+	Retries  int
+	Timeout  time.Duration
+}
+
+// slackText is the default text of Slack messages.
+const slackText = `Cleanup {{.Command}} on {{.Host}}: {{.Deleted}} tags deleted, {{.Skipped}} skipped, {{.Failed}} failed in {{len .Images}} images, {{bytes .BytesReclaimed}} reclaimed
+{{- range .Errors}}
+> {{.}}
+{{- end}}`
+
+var funcs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		content, err := marshal(value)
+		return string(content), err
+	},
+	"bytes": formatBytes,
+}
+
+// ParseTemplate checks a webhook template.
+func ParseTemplate(source string) (*template.Template, error) {
+	return template.New("webhook").Funcs(funcs).Parse(source)
+}
+
+// Payload renders the body posted for summary.
+func (w Webhook) Payload(summary Summary) ([]byte, error) {
+	source := w.Template
+	if source == "" && w.Format == FormatSlack {
+		source = slackText
+	}
+	if source == "" {
+		return marshal(summary)
+	}
+
+	tmpl, err := ParseTemplate(source)
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, summary); err != nil {
+		return nil, err
+	}
+	if w.Format == FormatSlack {
+		return marshal(map[string]string{"text": rendered.String()})
+	}
+	return rendered.Bytes(), nil
+}
+
+// marshal encodes value without escaping <, > and & for the quotes of
+// Slack messages.
+func marshal(value interface{}) ([]byte, error) {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(content.Bytes(), []byte("\n")), nil
+}
+
+// retryDelay is the wait before the first retry, doubled for each next one.
+var retryDelay = time.Second
+
+// Send posts summary to the webhook, retrying on errors and non 2xx status.
+func (w Webhook) Send(summary Summary) error {
+	payload, err := w.Payload(summary)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %v", w.URL, err)
+	}
+	client := &http.Client{Timeout: w.Timeout}
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		err = post(client, w.URL, payload)
+		if err == nil || attempt >= w.Retries {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("webhook %s: %v", w.URL, err)
+	}
+	return nil
+}
+
+func post(client *http.Client, url string, payload []byte) error {
+	response, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	return nil
+}
+
+// Notify sends summary to every webhook and returns their errors.
+func Notify(webhooks []Webhook, summary Summary) []error {
+	var errors []error
+	for _, webhook := range webhooks {
+		if err := webhook.Send(summary); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+func formatBytes(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%.1f", value), "0"), ".") + " " + units[i]
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testSummary() Summary {
+	summary := Summary{Command: "delete", Host: "ci"}
+	summary.Add(ImageSummary{Image: "r0mdau/nodejs", Deleted: 3, Skipped: 1, BytesReclaimed: 3 << 20})
+	summary.Add(ImageSummary{Image: "r0mdau/php", Deleted: 1, Failed: 1, BytesReclaimed: 512}, "r0mdau/php:pr-1: 401 Unauthorized")
+	return summary
+}
+
+func TestPayload(t *testing.T) {
+	summary := testSummary()
+	require.Equal(t, 4, summary.Deleted)
+	require.Equal(t, int64(3<<20+512), summary.BytesReclaimed)
+
+	generic, err := Webhook{Format: FormatGeneric}.Payload(summary)
+	require.NoError(t, err)
+	require.Contains(t, string(generic), `"images":[{"image":"r0mdau/nodejs","deleted":3,"skipped":1,"failed":0,"bytes_reclaimed":3145728}`)
+
+	slack, err := Webhook{Format: FormatSlack}.Payload(summary)
+	require.NoError(t, err)
+	require.Equal(t, `{"text":"Cleanup delete on ci: 4 tags deleted, 1 skipped, 1 failed in 2 images, 3 MB reclaimed\n> r0mdau/php:pr-1: 401 Unauthorized"}`, string(slack))
+
+	custom, err := Webhook{Format: FormatGeneric, Template: `{"failed": {{.Failed}}, "images": {{json .Images}}}`}.Payload(Summary{})
+	require.NoError(t, err)
+	require.Equal(t, `{"failed": 0, "images": null}`, string(custom))
+
+	_, err = Webhook{Template: "{{.Unknown}}"}.Payload(summary)
+	require.EqualError(t, err, `template: webhook:1:2: executing "webhook" at <.Unknown>: can't evaluate field Unknown in type notify.Summary`)
+}
+
+func TestSend(t *testing.T) {
+	retryDelay = time.Millisecond
+	attempts := 0
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	require.NoError(t, Webhook{URL: server.URL, Format: FormatSlack, Retries: 2, Timeout: time.Second}.Send(testSummary()))
+	require.Equal(t, 3, attempts)
+	require.Contains(t, string(body), `"text":"Cleanup delete`)
+
+	attempts = 0
+	errors := Notify([]Webhook{{URL: server.URL, Retries: 1, Timeout: time.Second}}, testSummary())
+	require.Len(t, errors, 1)
+	require.EqualError(t, errors[0], "webhook "+server.URL+": 503 Service Unavailable")
+	require.Equal(t, 2, attempts)
+}
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "512 B", formatBytes(512))
+	require.Equal(t, "1.5 KB", formatBytes(1536))
+	require.Equal(t, "2 GB", formatBytes(2<<30))
+}
@@ -0,0 +1,245 @@
+// Package schedule computes when the daemon runs: cron expressions, fixed
+// intervals and the maintenance windows deletes are allowed in.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next run time strictly after a given time, the zero
+// time when there is none.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Interval runs at a fixed period from the previous run.
+type Interval time.Duration
+
+func (i Interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// Window is a daily time range in minutes since midnight, it wraps around
+// midnight when End is before Start.
+type Window struct {
+	Start int
+	End   int
+}
+
+// ParseWindow parses a window like "01:00-05:30", local time.
+func ParseWindow(window string) (Window, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return Window{}, fmt.Errorf("invalid window \"%s\", use HH:MM-HH:MM ie 01:00-05:00", window)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window \"%s\", %v", window, err)
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window \"%s\", %v", window, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid window \"%s\", start and end are the same", window)
+	}
+	return Window{start, end}, nil
+}
+
+func parseClock(clock string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(clock), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("\"%s\" is not HH:MM", clock)
+	}
+	hour, errHour := strconv.Atoi(parts[0])
+	minute, errMinute := strconv.Atoi(parts[1])
+	if errHour != nil || errMinute != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("\"%s\" is not HH:MM", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// Contains tells if t is inside the window.
+func (w Window) Contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return minutes >= w.Start && minutes < w.End
+	}
+	return minutes >= w.Start || minutes < w.End
+}
+
+// Until is the end of the window after t, the end of the window containing t
+// when it is inside.
+func (w Window) Until(t time.Time) time.Time {
+	end := time.Date(t.Year(), t.Month(), t.Day(), 0, w.End, 0, 0, t.Location())
+	if !end.After(t) {
+		end = time.Date(t.Year(), t.Month(), t.Day()+1, 0, w.End, 0, 0, t.Location())
+	}
+	return end
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// Cron is a standard 5 fields cron expression: minute, hour, day of month,
+// month and day of week.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// a restricted day of month or day of week matches either one, like cron
+	domStar, dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var fields = []field{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses expressions like "30 3 * * 1-5", "*/15 * * * *" or
+// "@daily", names of months and days of week are allowed.
+func ParseCron(expression string) (Cron, error) {
+	source := strings.TrimSpace(expression)
+	if macro, ok := macros[strings.ToLower(source)]; ok {
+		source = macro
+	}
+	parts := strings.Fields(source)
+	if len(parts) != len(fields) {
+		return Cron{}, fmt.Errorf("invalid cron expression \"%s\", expected 5 fields, got %d", expression, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		var err error
+		if bits[i], err = fields[i].parse(part); err != nil {
+			return Cron{}, fmt.Errorf("invalid cron expression \"%s\", %s: %v", expression, fields[i].name, err)
+		}
+	}
+	// 7 is also sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return Cron{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func (f field) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expression, ",") {
+		rangeExpression, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rangeExpression = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step \"%s\"", item[i+1:])
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangeExpression == "*":
+		case strings.Contains(rangeExpression, "-"):
+			bounds := strings.SplitN(rangeExpression, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range \"%s\"", rangeExpression)
+			}
+		default:
+			var err error
+			if low, err = f.value(rangeExpression); err != nil {
+				return 0, err
+			}
+			// a single value with a step runs until the max, like 5/15
+			high = low
+			if step > 1 {
+				high = f.max
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(expression string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(expression, name) {
+			return i + f.min, nil
+		}
+	}
+	value, err := strconv.Atoi(expression)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value \"%s\"", expression)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("%d is out of %d-%d", value, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Next returns the first minute matching the expression after after, in
+// its location, the zero time when none does within five years like for
+// "0 0 30 2 *".
+func (c Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c Cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
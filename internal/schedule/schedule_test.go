@@ -0,0 +1,92 @@
+package schedule
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	// a monday
+	start := time.Date(2021, 3, 15, 10, 17, 30, 0, time.UTC)
+
+	tdata := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 15, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2021, 3, 15, 10, 25, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2021, 3, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 9-11 * * *", time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * sat,sun", time.Date(2021, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@hourly", time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tdata {
+		t.Run(test.expression, func(t *testing.T) {
+			cron, err := ParseCron(test.expression)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, cron.Next(start))
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tdata := []struct {
+		expression string
+		expected   string
+	}{
+		{"* * *", "invalid cron expression \"* * *\", expected 5 fields, got 3"},
+		{"60 * * * *", "invalid cron expression \"60 * * * *\", minute: 60 is out of 0-59"},
+		{"* * * foo *", "invalid cron expression \"* * * foo *\", month: invalid value \"foo\""},
+		{"*/0 * * * *", "invalid cron expression \"*/0 * * * *\", minute: invalid step \"0\""},
+		{"* 5-2 * * *", "invalid cron expression \"* 5-2 * * *\", hour: invalid range \"5-2\""},
+	}
+	for _, test := range tdata {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := ParseCron(test.expression)
+			require.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func TestInterval(t *testing.T) {
+	start := time.Date(2021, 3, 15, 10, 17, 30, 0, time.UTC)
+	require.Equal(t, start.Add(6*time.Hour), Interval(6*time.Hour).Next(start))
+}
+
+func TestWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 3, 15, hour, minute, 0, 0, time.UTC)
+	}
+
+	night, err := ParseWindow("01:00-05:30")
+	require.NoError(t, err)
+	require.Equal(t, "01:00-05:30", night.String())
+	require.False(t, night.Contains(at(0, 59)))
+	require.True(t, night.Contains(at(1, 0)))
+	require.True(t, night.Contains(at(5, 29)))
+	require.False(t, night.Contains(at(5, 30)))
+	require.Equal(t, at(5, 30), night.Until(at(1, 0)))
+
+	wrapping, err := ParseWindow("22:00-02:00")
+	require.NoError(t, err)
+	require.True(t, wrapping.Contains(at(23, 0)))
+	require.True(t, wrapping.Contains(at(1, 0)))
+	require.False(t, wrapping.Contains(at(12, 0)))
+	require.Equal(t, at(2, 0).AddDate(0, 0, 1), wrapping.Until(at(23, 0)))
+	require.Equal(t, at(2, 0), wrapping.Until(at(1, 0)))
+
+	_, err = ParseWindow("01:00")
+	require.EqualError(t, err, "invalid window \"01:00\", use HH:MM-HH:MM ie 01:00-05:00")
+	_, err = ParseWindow("01:00-25:00")
+	require.EqualError(t, err, "invalid window \"01:00-25:00\", \"25:00\" is not HH:MM")
+	_, err = ParseWindow("01:00-01:00")
+	require.EqualError(t, err, "invalid window \"01:00-01:00\", start and end are the same")
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"io"
+	"net/http"
+)
+
+func (r Registry) BlobExists(image, digest string) (bool, error) {
+	response, err := r.Client.Head(r.BaseUrl + "/v2/" + image + "/blobs/" + digest)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, r.httpErr(response, "Error while checking blob: "+image+"@"+digest)
+}
+
+// MountBlob asks the registry to mount a blob from another repository. When
+// the registry refuses the mount, it opens a regular upload session instead
+// and MountBlob returns false with its location, to finish with PutBlob.
+func (r Registry) MountBlob(image, digest, from string) (bool, string, error) {
+	response, err := r.Client.Post(r.BaseUrl+"/v2/"+image+"/blobs/uploads/?mount="+digest+"&from="+from, "", nil)
+	if err != nil {
+		return false, "", err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusCreated:
+		return true, "", nil
+	case http.StatusAccepted:
+		location, err := response.Location()
+		if err != nil {
+			return false, "", err
+		}
+		return false, location.String(), nil
+	}
+	return false, "", r.httpErr(response, "Error while mounting blob: "+image+"@"+digest+" from "+from)
+}
+
+// transferClient is the client of blob transfers, a layer may take longer
+// to stream than the total timeout of API requests, the transport still
+// bounds the dial, the TLS handshake and the wait for the response.
+func (r Registry) transferClient() *http.Client {
+	client := *r.Client
+	client.Timeout = 0
+	return &client
+}
+
+func (r Registry) GetBlob(image, digest string) (io.ReadCloser, int64, error) {
+	response, err := r.transferClient().Get(r.BaseUrl + "/v2/" + image + "/blobs/" + digest)
+	if err != nil {
+		return nil, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, 0, r.httpErr(response, "Error while getting blob: "+image+"@"+digest)
+	}
+	return response.Body, response.ContentLength, nil
+}
+
+func (r Registry) UploadBlob(image, digest string, content io.Reader, size int64) error {
+	response, err := r.Client.Post(r.BaseUrl+"/v2/"+image+"/blobs/uploads/", "", nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		return r.httpErr(response, "Error while starting blob upload: "+image+"@"+digest)
+	}
+
+	// Location may be relative to the registry
+	location, err := response.Location()
+	if err != nil {
+		return err
+	}
+	return r.PutBlob(location.String(), image, digest, content, size)
+}
+
+// PutBlob uploads the content of a blob in one request to the upload
+// session at location, the digest closes the session.
+func (r Registry) PutBlob(location, image, digest string, content io.Reader, size int64) error {
+	request, err := http.NewRequest("PUT", location, content)
+	if err != nil {
+		return err
+	}
+	query := request.URL.Query()
+	query.Set("digest", digest)
+	request.URL.RawQuery = query.Encode()
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.ContentLength = size
+	response, err := r.transferClient().Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		return r.httpErr(response, "Error while uploading blob: "+image+"@"+digest)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newStatusResponse(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+		Header:     header,
+	}
+}
+
+func TestRegistryBlob(t *testing.T) {
+	tdata := []struct {
+		testCase      string
+		status        int
+		expected      bool
+		expectedError bool
+	}{
+		{"BlobExists should return true if 200", http.StatusOK, true, false},
+		{"BlobExists should return false if 404", http.StatusNotFound, false, false},
+		{"BlobExists should return error if 401", http.StatusUnauthorized, false, true},
+	}
+
+	for _, test := range tdata {
+		t.Run(test.testCase, func(t *testing.T) {
+			client := NewTestClient(func(req *http.Request) *http.Response {
+				require.Equal(t, "HEAD", req.Method)
+				require.Equal(t, url+"/v2/image/blobs/sha256:layer", req.URL.String())
+				return newStatusResponse(test.status, nil)
+			})
+
+			api := Registry{client, url}
+			exists, err := api.BlobExists("image", "sha256:layer")
+			require.Equal(t, test.expected, exists)
+			require.Equal(t, test.expectedError, err != nil)
+		})
+	}
+
+	t.Run("MountBlob should return true if 201", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			require.Equal(t, "POST", req.Method)
+			require.Equal(t, url+"/v2/image/blobs/uploads/?mount=sha256:layer&from=other", req.URL.String())
+			return newStatusResponse(http.StatusCreated, nil)
+		})
+
+		api := Registry{client, url}
+		mounted, session, err := api.MountBlob("image", "sha256:layer", "other")
+		require.True(t, mounted)
+		require.Empty(t, session)
+		require.NoError(t, err)
+	})
+
+	t.Run("MountBlob should return the upload session the registry opened instead", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			header := make(http.Header)
+			header.Set("Location", "/v2/image/blobs/uploads/uuid")
+			return newStatusResponse(http.StatusAccepted, header)
+		})
+
+		api := Registry{client, url}
+		mounted, session, err := api.MountBlob("image", "sha256:layer", "other")
+		require.False(t, mounted)
+		require.Equal(t, url+"/v2/image/blobs/uploads/uuid", session)
+		require.NoError(t, err)
+	})
+
+	t.Run("UploadBlob should start an upload and put content on relative location", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			if req.Method == "POST" {
+				require.Equal(t, url+"/v2/image/blobs/uploads/", req.URL.String())
+				header := make(http.Header)
+				header.Set("Location", "/v2/image/blobs/uploads/uuid?_state=abc")
+				return newStatusResponse(http.StatusAccepted, header)
+			}
+			require.Equal(t, "PUT", req.Method)
+			require.Equal(t, url+"/v2/image/blobs/uploads/uuid?_state=abc&digest=sha256%3Alayer", req.URL.String())
+			body, _ := ioutil.ReadAll(req.Body)
+			require.Equal(t, "content", string(body))
+			return newStatusResponse(http.StatusCreated, nil)
+		})
+
+		api := Registry{client, url}
+		err := api.UploadBlob("image", "sha256:layer", bytes.NewBufferString("content"), 7)
+		require.NoError(t, err)
+	})
+
+	t.Run("GetBlob should return error if StatusCode != 200", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			return newStatusResponse(http.StatusNotFound, nil)
+		})
+
+		api := Registry{client, url}
+		_, _, err := api.GetBlob("image", "sha256:layer")
+		require.Error(t, err)
+	})
+
+	t.Run("GetBlob should stream longer than the timeout of API requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("content"))
+		}))
+		defer server.Close()
+
+		api := Registry{&http.Client{Timeout: 50 * time.Millisecond}, server.URL}
+		blob, _, err := api.GetBlob("image", "sha256:layer")
+		require.NoError(t, err)
+		defer blob.Close()
+		content, err := ioutil.ReadAll(blob)
+		require.NoError(t, err)
+		require.Equal(t, "content", string(content))
+	})
+}
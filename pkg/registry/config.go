@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ImageConfig struct {
+	Created      time.Time `json:"created"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// GetImageConfig returns the config blob of image:reference, the one of the
+// first platform for multi-arch images.
+func (r Registry) GetImageConfig(image, reference string) (ImageConfig, error) {
+	response, err := r.GetManifest(image, reference)
+	if err != nil {
+		return ImageConfig{}, err
+	}
+	manifest := response.GetManifest()
+	if manifest.IsIndex() && len(manifest.Manifests) > 0 {
+		return r.GetImageConfig(image, manifest.Manifests[0].Digest)
+	}
+
+	return r.getConfigBlob(image, manifest.Config.Digest)
+}
+
+func (r Registry) getConfigBlob(image, digest string) (ImageConfig, error) {
+	content, _, err := r.GetBlob(image, digest)
+	if err != nil {
+		return ImageConfig{}, err
+	}
+	defer content.Close()
+	var config ImageConfig
+	err = json.NewDecoder(content).Decode(&config)
+	return config, err
+}
@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRegistryImageConfig(t *testing.T) {
+	t.Run("GetImageConfig should follow the first platform of multi-arch images", func(t *testing.T) {
+		var calls []string
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			calls = append(calls, req.URL.Path)
+			body := `{"created":"2024-01-31T15:30:00Z","architecture":"amd64","os":"linux","config":{"Labels":{"team":"web"}}}`
+			switch req.URL.Path {
+			case "/v2/image/manifests/multi":
+				body = indexManifest
+			case "/v2/image/manifests/sha256:amd64":
+				body = imageManifest
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Header:     make(http.Header),
+			}
+		})
+
+		api := Registry{client, url}
+		config, err := api.GetImageConfig("image", "multi")
+
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC), config.Created)
+		require.Equal(t, "amd64", config.Architecture)
+		require.Equal(t, map[string]string{"team": "web"}, config.Config.Labels)
+		require.Equal(t, []string{"/v2/image/manifests/multi", "/v2/image/manifests/sha256:amd64", "/v2/image/blobs/sha256:config"}, calls)
+	})
+
+	t.Run("GetImageConfig should return error if manifest is missing", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			return newStatusResponse(http.StatusNotFound, nil)
+		})
+
+		api := Registry{client, url}
+		_, err := api.GetImageConfig("image", "missing")
+		require.Error(t, err)
+	})
+}
+
+func TestRegistryImageInfo(t *testing.T) {
+	sizedManifest := `{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"digest":"sha256:config","size":100},"layers":[{"digest":"sha256:layer1","size":1000},{"digest":"sha256:layer2","size":2000}]}`
+	config := `{"created":"2024-01-31T15:30:00Z","architecture":"amd64","os":"linux","config":{"Labels":{"team":"web"}}}`
+
+	t.Run("GetImageInfo should read digest, size, created, labels and platform", func(t *testing.T) {
+		var calls []string
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			calls = append(calls, req.URL.Path)
+			header := make(http.Header)
+			body := config
+			if req.URL.Path == "/v2/image/manifests/tag" {
+				header.Set("Docker-Content-Digest", "sha256:tag")
+				body = sizedManifest
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Header:     header,
+			}
+		})
+
+		api := Registry{client, url}
+		info, err := api.GetImageInfo("image", "tag")
+
+		require.NoError(t, err)
+		require.Equal(t, ImageInfo{
+			Digest:   "sha256:tag",
+			Size:     3100,
+			Created:  time.Date(2024, 1, 31, 15, 30, 0, 0, time.UTC),
+			Labels:   map[string]string{"team": "web"},
+			Platform: "linux/amd64",
+		}, info)
+		require.Equal(t, []string{"/v2/image/manifests/tag", "/v2/image/blobs/sha256:config"}, calls)
+	})
+
+	t.Run("GetImageInfo should sum the platforms of multi-arch images", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			body := config
+			switch req.URL.Path {
+			case "/v2/image/manifests/multi":
+				body = indexManifest
+			case "/v2/image/manifests/sha256:amd64", "/v2/image/manifests/sha256:arm64":
+				body = sizedManifest
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Header:     make(http.Header),
+			}
+		})
+
+		api := Registry{client, url}
+		info, err := api.GetImageInfo("image", "multi")
+
+		require.NoError(t, err)
+		require.Equal(t, int64(6200), info.Size)
+		require.Equal(t, "linux/amd64,linux/arm64", info.Platform)
+		require.Equal(t, map[string]string{"team": "web"}, info.Labels)
+		require.Regexp(t, "^sha256:[0-9a-f]{64}$", info.Digest)
+	})
+}
@@ -0,0 +1,60 @@
+package registry
+
+// CopyTag transfers image:tag from src to dst as dstImage:tag. Children of
+// multi-arch manifests are copied first, then blobs, then the manifest itself
+// so the destination never references missing content.
+func CopyTag(src, dst Registry, srcImage, dstImage, tag string) error {
+	return copyManifest(src, dst, srcImage, dstImage, tag)
+}
+
+func copyManifest(src, dst Registry, srcImage, dstImage, reference string) error {
+	response, err := src.GetManifest(srcImage, reference)
+	if err != nil {
+		return err
+	}
+	manifest := response.GetManifest()
+
+	for _, child := range manifest.Manifests {
+		if err := copyManifest(src, dst, srcImage, dstImage, child.Digest); err != nil {
+			return err
+		}
+	}
+	for _, digest := range manifest.Blobs() {
+		if err := copyBlob(src, dst, srcImage, dstImage, digest); err != nil {
+			return err
+		}
+	}
+
+	mediaType := response.Header.Get("Content-Type")
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+	return dst.PutManifest(dstImage, reference, mediaType, response.Body)
+}
+
+func copyBlob(src, dst Registry, srcImage, dstImage, digest string) error {
+	exists, err := dst.BlobExists(dstImage, digest)
+	if err != nil || exists {
+		return err
+	}
+
+	session := ""
+	if src.BaseUrl == dst.BaseUrl {
+		var mounted bool
+		mounted, session, err = dst.MountBlob(dstImage, digest, srcImage)
+		if err != nil || mounted {
+			return err
+		}
+	}
+
+	content, size, err := src.GetBlob(srcImage, digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	// a refused mount already opened the upload session
+	if session != "" {
+		return dst.PutBlob(session, dstImage, digest, content, size)
+	}
+	return dst.UploadBlob(dstImage, digest, content, size)
+}
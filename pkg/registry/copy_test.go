@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry records the calls made against it and serves manifests and
+// blobs from memory.
+type fakeRegistry struct {
+	mutex     sync.Mutex
+	manifests map[string]string
+	blobs     map[string]bool
+	calls     []string
+	// refuseMount answers mounts with a regular upload session
+	refuseMount bool
+}
+
+func (f *fakeRegistry) roundTrip(req *http.Request) *http.Response {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, req.Method+" "+req.URL.RequestURI())
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.Method == "GET" && strings.Contains(path, "/manifests/"):
+		if body, ok := f.manifests[path]; ok {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Header:     make(http.Header),
+			}
+		}
+		return newStatusResponse(http.StatusNotFound, nil)
+	case req.Method == "PUT" && strings.Contains(path, "/manifests/"):
+		body, _ := ioutil.ReadAll(req.Body)
+		f.manifests[path] = string(body)
+		return newStatusResponse(http.StatusCreated, nil)
+	case req.Method == "HEAD" && strings.Contains(path, "/blobs/"):
+		if f.blobs[path] {
+			return newStatusResponse(http.StatusOK, nil)
+		}
+		return newStatusResponse(http.StatusNotFound, nil)
+	case req.Method == "GET" && strings.Contains(path, "/blobs/"):
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("blob")),
+			Header:     make(http.Header),
+		}
+	case req.Method == "POST" && req.URL.Query().Get("mount") != "" && !f.refuseMount:
+		image := strings.TrimSuffix(path, "/blobs/uploads/")
+		f.blobs[image+"/blobs/"+req.URL.Query().Get("mount")] = true
+		return newStatusResponse(http.StatusCreated, nil)
+	case req.Method == "POST":
+		header := make(http.Header)
+		header.Set("Location", "/v2/"+path+"uuid")
+		return newStatusResponse(http.StatusAccepted, header)
+	case req.Method == "PUT":
+		image := strings.TrimSuffix(path, "/blobs/uploads/uuid")
+		f.blobs[image+"/blobs/"+req.URL.Query().Get("digest")] = true
+		return newStatusResponse(http.StatusCreated, nil)
+	}
+	return newStatusResponse(http.StatusNotImplemented, nil)
+}
+
+func (f *fakeRegistry) count(prefix string) int {
+	count := 0
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		manifests: map[string]string{
+			"image/manifests/multi":        indexManifest,
+			"image/manifests/sha256:amd64": imageManifest,
+			"image/manifests/sha256:arm64": imageManifest,
+			"image/manifests/single":       imageManifest,
+		},
+		blobs: map[string]bool{
+			"image/blobs/sha256:config": true,
+			"image/blobs/sha256:layer1": true,
+			"image/blobs/sha256:layer2": true,
+		},
+	}
+}
+
+func TestCopyTag(t *testing.T) {
+	t.Run("CopyTag between registries should upload blobs then manifests", func(t *testing.T) {
+		src, dst := newFakeRegistry(), newFakeRegistry()
+		dst.manifests = map[string]string{}
+		dst.blobs = map[string]bool{"backup/blobs/sha256:config": true}
+
+		err := CopyTag(
+			Registry{NewTestClient(src.roundTrip), "https://src.example.com"},
+			Registry{NewTestClient(dst.roundTrip), "https://dst.example.com"},
+			"image", "backup", "multi",
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, indexManifest, dst.manifests["backup/manifests/multi"])
+		require.Equal(t, imageManifest, dst.manifests["backup/manifests/sha256:amd64"])
+		require.Equal(t, imageManifest, dst.manifests["backup/manifests/sha256:arm64"])
+		require.True(t, dst.blobs["backup/blobs/sha256:layer1"])
+		require.True(t, dst.blobs["backup/blobs/sha256:layer2"])
+		// config already existed, layers are shared between both platforms
+		require.Equal(t, 2, src.count("GET /v2/image/blobs/"))
+		require.Equal(t, 0, dst.count("POST /v2/backup/blobs/uploads/?mount="))
+		require.Equal(t, "PUT /v2/backup/manifests/multi", dst.calls[len(dst.calls)-1])
+	})
+
+	t.Run("CopyTag on the same registry should mount blobs", func(t *testing.T) {
+		fake := newFakeRegistry()
+		api := Registry{NewTestClient(fake.roundTrip), url}
+
+		err := CopyTag(api, api, "image", "backup", "single")
+
+		require.NoError(t, err)
+		require.Equal(t, imageManifest, fake.manifests["backup/manifests/single"])
+		require.True(t, fake.blobs["backup/blobs/sha256:config"])
+		require.Equal(t, 0, fake.count("GET /v2/image/blobs/"))
+		require.Equal(t, 3, fake.count("POST /v2/backup/blobs/uploads/?mount=sha256:"))
+		require.Equal(t, 1, fake.count("POST /v2/backup/blobs/uploads/?mount=sha256:config&from=image"))
+	})
+
+	t.Run("CopyTag should upload to the session opened by a refused mount", func(t *testing.T) {
+		fake := newFakeRegistry()
+		fake.refuseMount = true
+		api := Registry{NewTestClient(fake.roundTrip), url}
+
+		err := CopyTag(api, api, "image", "backup", "single")
+
+		require.NoError(t, err)
+		require.True(t, fake.blobs["backup/blobs/sha256:config"])
+		require.Equal(t, 3, fake.count("POST /v2/backup/blobs/uploads/"))
+		require.Equal(t, 3, fake.count("POST /v2/backup/blobs/uploads/?mount="))
+		require.Equal(t, 3, fake.count("PUT /v2/backup/blobs/uploads/uuid?digest="))
+	})
+
+	t.Run("CopyTag should return error if source manifest is missing", func(t *testing.T) {
+		fake := newFakeRegistry()
+		api := Registry{NewTestClient(fake.roundTrip), url}
+
+		err := CopyTag(api, api, "image", "backup", "missing")
+
+		require.Error(t, err)
+		require.Equal(t, 0, fake.count("PUT"))
+	})
+}
@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImageInfo describes an image for retention policies.
+type ImageInfo struct {
+	Digest  string
+	Size    int64
+	Created time.Time
+	Labels  map[string]string
+	// Platform is os/architecture, comma separated for multi-arch images
+	Platform string
+}
+
+// GetImageInfo reads the manifest and config of image:reference. Size is the
+// sum of the config and layer sizes of every platform, created and labels
+// are those of the first platform for multi-arch images.
+func (r Registry) GetImageInfo(image, reference string) (ImageInfo, error) {
+	response, err := r.GetManifest(image, reference)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	info := ImageInfo{Digest: response.Header.Get("Docker-Content-Digest")}
+	if info.Digest == "" {
+		info.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(response.Body))
+	}
+
+	manifest := response.GetManifest()
+	if !manifest.IsIndex() {
+		info.Size = manifestSize(manifest)
+		config, err := r.getConfigBlob(image, manifest.Config.Digest)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		info.Created, info.Labels = config.Created, config.Config.Labels
+		info.Platform = config.OS + "/" + config.Architecture
+		return info, nil
+	}
+
+	var platforms []string
+	for _, child := range manifest.Manifests {
+		childResponse, err := r.GetManifest(image, child.Digest)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		info.Size += manifestSize(childResponse.GetManifest())
+		if child.Platform != nil && child.Platform.OS != "unknown" {
+			platforms = append(platforms, child.Platform.OS+"/"+child.Platform.Architecture)
+		}
+	}
+	info.Platform = strings.Join(platforms, ",")
+	if len(manifest.Manifests) > 0 {
+		config, err := r.GetImageConfig(image, manifest.Manifests[0].Digest)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		info.Created, info.Labels = config.Created, config.Config.Labels
+	}
+	return info, nil
+}
+
+func manifestSize(manifest Manifest) int64 {
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
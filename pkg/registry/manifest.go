@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestMediaTypes = []string{
+	MediaTypeDockerManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type Descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+type Manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    Descriptor   `json:"config"`
+	Layers    []Descriptor `json:"layers"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// IsIndex tells if the manifest is a manifest list (multi-arch image)
+// whose children are other manifests.
+func (m Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeDockerManifestList || m.MediaType == MediaTypeOCIIndex || len(m.Manifests) > 0
+}
+
+// Blobs returns the config and layer digests referenced by the manifest.
+func (m Manifest) Blobs() []string {
+	var blobs []string
+	if m.Config.Digest != "" {
+		blobs = append(blobs, m.Config.Digest)
+	}
+	for _, layer := range m.Layers {
+		blobs = append(blobs, layer.Digest)
+	}
+	return blobs
+}
+
+func (r Response) GetManifest() Manifest {
+	var manifest Manifest
+	err := json.Unmarshal(r.Body, &manifest)
+	r.logError(err)
+	if manifest.MediaType == "" {
+		manifest.MediaType = r.Header.Get("Content-Type")
+	}
+	return manifest
+}
+
+func (r Registry) GetManifest(image, reference string) (Response, error) {
+	request, _ := http.NewRequest("GET", r.BaseUrl+"/v2/"+image+"/manifests/"+reference, nil)
+	request.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	response, err := r.Client.Do(request)
+	if err != nil {
+		return Response{}, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	rResponse := NewResponse(
+		body,
+		response.Header,
+		response.StatusCode,
+	)
+	if err == nil && response.StatusCode != http.StatusOK {
+		err = r.httpErr(response, "Error while getting manifest for: "+image+":"+reference)
+	}
+	return rResponse, err
+}
+
+func (r Registry) PutManifest(image, reference, mediaType string, body []byte) error {
+	request, _ := http.NewRequest("PUT", r.BaseUrl+"/v2/"+image+"/manifests/"+reference, bytes.NewReader(body))
+	request.Header.Set("Content-Type", mediaType)
+	response, err := r.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		return r.httpErr(response, "Error while putting manifest for: "+image+":"+reference)
+	}
+	return nil
+}
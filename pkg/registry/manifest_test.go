@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+const imageManifest = `{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"digest":"sha256:config"},"layers":[{"digest":"sha256:layer1"},{"digest":"sha256:layer2"}]}`
+
+const indexManifest = `{"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"sha256:amd64","platform":{"architecture":"amd64","os":"linux"}},{"digest":"sha256:arm64","platform":{"architecture":"arm64","os":"linux"}}]}`
+
+func TestRegistryManifest(t *testing.T) {
+	t.Run("GetManifest from Response should return image Manifest", func(t *testing.T) {
+		rResponse := NewResponse([]byte(imageManifest), http.Header{}, 200)
+		manifest := rResponse.GetManifest()
+
+		require.False(t, manifest.IsIndex())
+		require.Equal(t, []string{"sha256:config", "sha256:layer1", "sha256:layer2"}, manifest.Blobs())
+	})
+
+	t.Run("GetManifest from Response should return index Manifest", func(t *testing.T) {
+		rResponse := NewResponse([]byte(indexManifest), http.Header{}, 200)
+		manifest := rResponse.GetManifest()
+
+		require.True(t, manifest.IsIndex())
+		require.Equal(t, []string(nil), manifest.Blobs())
+		require.Equal(t, "arm64", manifest.Manifests[1].Platform.Architecture)
+	})
+
+	t.Run("GetManifest from Response should fallback on Content-Type media type", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Content-Type", MediaTypeDockerManifestList)
+		rResponse := NewResponse([]byte(`{"manifests":[]}`), header, 200)
+
+		require.True(t, rResponse.GetManifest().IsIndex())
+	})
+
+	t.Run("GetManifest API with roundtripper should accept multi-arch media types", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			require.Equal(t, url+"/v2/image/manifests/tag", req.URL.String())
+			require.Contains(t, req.Header.Get("Accept"), MediaTypeOCIIndex)
+			require.Contains(t, req.Header.Get("Accept"), MediaTypeDockerManifestList)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(imageManifest)),
+				Header:     make(http.Header),
+			}
+		})
+
+		api := Registry{client, url}
+		response, err := api.GetManifest("image", "tag")
+		require.Equal(t, []byte(imageManifest), response.Body)
+		require.NoError(t, err)
+	})
+
+	t.Run("GetManifest API with roundtripper should return error if StatusCode != 200", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+				Header:     make(http.Header),
+			}
+		})
+
+		api := Registry{client, url}
+		_, err := api.GetManifest("image", "tag")
+		require.Error(t, err)
+	})
+
+	t.Run("PutManifest API with roundtripper should send media type and body", func(t *testing.T) {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			require.Equal(t, "PUT", req.Method)
+			require.Equal(t, url+"/v2/image/manifests/tag", req.URL.String())
+			require.Equal(t, MediaTypeDockerManifest, req.Header.Get("Content-Type"))
+			body, _ := ioutil.ReadAll(req.Body)
+			require.Equal(t, imageManifest, string(body))
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+				Header:     make(http.Header),
+			}
+		})
+
+		api := Registry{client, url}
+		err := api.PutManifest("image", "tag", MediaTypeDockerManifest, []byte(imageManifest))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Observer is called after each request to the registry with the API
+// endpoint, the method, the response status, 0 on transport errors, and
+// the latency.
+type Observer func(endpoint, method string, status int, duration time.Duration)
+
+// Observe returns a copy of r whose requests are reported to observer.
+func (r Registry) Observe(observer Observer) Registry {
+	client := *r.Client
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.Transport = observedTransport{next, observer}
+	return Registry{Client: &client, BaseUrl: r.BaseUrl}
+}
+
+type observedTransport struct {
+	next     http.RoundTripper
+	observer Observer
+}
+
+func (t observedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+	status := 0
+	if err == nil {
+		status = response.StatusCode
+	}
+	t.observer(Endpoint(request.URL.Path), request.Method, status, time.Since(start))
+	return response, err
+}
+
+// Endpoint names the registry API endpoint of a request path, without the
+// image name and references so that it can label metrics.
+func Endpoint(path string) string {
+	switch {
+	case path == "/v2/" || path == "/v2":
+		return "version"
+	case path == "/v2/_catalog":
+		return "catalog"
+	case strings.HasSuffix(path, "/tags/list"):
+		return "tags"
+	case strings.Contains(path, "/manifests/"):
+		return "manifests"
+	case strings.Contains(path, "/blobs/uploads"):
+		return "blob_uploads"
+	case strings.Contains(path, "/blobs/"):
+		return "blobs"
+	}
+	return "other"
+}
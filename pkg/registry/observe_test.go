@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRegistryObserve(t *testing.T) {
+	var observed []string
+	registry := Registry{
+		Client: NewTestClient(func(req *http.Request) *http.Response {
+			response := getHttpResponse()
+			if req.Method == http.MethodDelete {
+				response.StatusCode = http.StatusAccepted
+			}
+			return response
+		}),
+		BaseUrl: url,
+	}.Observe(func(endpoint, method string, status int, duration time.Duration) {
+		observed = append(observed, method+" "+endpoint+" "+http.StatusText(status))
+	})
+
+	_, err := registry.ListImageTags("r0mdau/nodejs")
+	require.NoError(t, err)
+	require.NoError(t, registry.DeleteImage("r0mdau/nodejs", "pr-1", "sha256:1"))
+	require.Equal(t, []string{"GET tags OK", "DELETE manifests Accepted"}, observed)
+}
+
+func TestEndpoint(t *testing.T) {
+	tdata := map[string]string{
+		"/v2/":                                "version",
+		"/v2/_catalog":                        "catalog",
+		"/v2/r0mdau/nodejs/tags/list":         "tags",
+		"/v2/r0mdau/nodejs/manifests/latest":  "manifests",
+		"/v2/r0mdau/nodejs/blobs/sha256:1":    "blobs",
+		"/v2/r0mdau/nodejs/blobs/uploads/abc": "blob_uploads",
+		"/health":                             "other",
+	}
+	for path, expected := range tdata {
+		require.Equal(t, expected, Endpoint(path), path)
+	}
+}
@@ -2,9 +2,9 @@ package registry
 
 import (
 	"crypto/tls"
-	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,17 +15,25 @@ type Registry struct {
 	BaseUrl string
 }
 
+// requestTimeout bounds API requests, and each step of blob transfers.
+const requestTimeout = 30 * time.Second
+
 func NewRegistry(url string, insecure bool) Registry {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: requestTimeout, KeepAlive: requestTimeout}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: requestTimeout,
+		IdleConnTimeout:       90 * time.Second,
 	}
 	if insecure {
-		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
 		}
-		client.Transport = transport
+	}
+	client := &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
 	}
 	return Registry{
 		Client:  client,
@@ -106,6 +114,16 @@ func (r Registry) DeleteImage(image, tag, digest string) error {
 	return nil
 }
 
+// HTTPError is a response of the registry with an unexpected status code.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%d %s : %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
 func (r Registry) httpErr(response *http.Response, message string) error {
-	return errors.New(fmt.Sprintf("%d %s : %s", response.StatusCode, http.StatusText(response.StatusCode), message))
+	return &HTTPError{StatusCode: response.StatusCode, Message: message}
 }
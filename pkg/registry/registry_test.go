@@ -3,6 +3,7 @@ package registry
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"net/http"
@@ -13,7 +14,10 @@ import (
 type RoundTripFunc func(req *http.Request) *http.Response
 
 func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
-	return f(req), nil
+	response := f(req)
+	// like http.Transport, needed to resolve relative Location headers
+	response.Request = req
+	return response, nil
 }
 
 func NewTestClient(fn RoundTripFunc) *http.Client {
@@ -34,33 +38,21 @@ func getHttpResponse() *http.Response {
 
 func TestRegistry(t *testing.T) {
 	t.Run("NewRegistry secure (default) configuration", func(t *testing.T) {
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-		}
-		expectedRegistry := Registry{
-			client,
-			url,
-		}
 		actualRegistry := NewRegistry(url, false)
-		require.Equal(t, expectedRegistry, actualRegistry)
+		require.Equal(t, url, actualRegistry.BaseUrl)
+		require.Equal(t, 30*time.Second, actualRegistry.Client.Timeout)
+		transport := actualRegistry.Client.Transport.(*http.Transport)
+		require.Nil(t, transport.TLSClientConfig)
+		require.Equal(t, 10*time.Second, transport.TLSHandshakeTimeout)
+		require.Equal(t, 30*time.Second, transport.ResponseHeaderTimeout)
 	})
 
 	t.Run("NewRegistry insecure configuration", func(t *testing.T) {
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-		}
-		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		}
-		client.Transport = transport
-		expectedRegistry := Registry{
-			client,
-			url,
-		}
 		actualRegistry := NewRegistry(url, true)
-		require.Equal(t, expectedRegistry, actualRegistry)
+		require.Equal(t, 30*time.Second, actualRegistry.Client.Timeout)
+		transport := actualRegistry.Client.Transport.(*http.Transport)
+		require.Equal(t, &tls.Config{InsecureSkipVerify: true}, transport.TLSClientConfig)
+		require.Equal(t, 30*time.Second, transport.ResponseHeaderTimeout)
 	})
 
 	expectedResponse := NewResponse(
@@ -166,5 +158,10 @@ func TestRegistry(t *testing.T) {
 		api := Registry{client, url}
 		err := api.DeleteImage("image", "tag", "sha256sum")
 		require.Error(t, err)
+
+		var httpErr *HTTPError
+		require.True(t, errors.As(err, &httpErr))
+		require.Equal(t, http.StatusOK, httpErr.StatusCode)
+		require.Equal(t, "200 OK : Error while deleting image:tag : image:tag", err.Error())
 	})
 }